@@ -0,0 +1,100 @@
+package live
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+	"sync"
+)
+
+// AdmissionKeyFunc gets the key a websocket connection is counted against, for
+// example the client IP or the authenticated user. The principal is the one
+// returned by the engines Authenticator, nil if there isn't one.
+type AdmissionKeyFunc func(r *http.Request, principal any) string
+
+// AdmissionLimits limits the number of websocket connections an engine accepts.
+type AdmissionLimits struct {
+	// MaxSockets the total number of connections. Zero means no limit.
+	MaxSockets int
+	// MaxSocketsPerKey the number of connections for a single key. Zero
+	// means no limit.
+	MaxSocketsPerKey int
+	// Key gets the key for a connection. Defaults to the client IP taken
+	// from the connection, X-Forwarded-For is not trusted, so behind a proxy
+	// use a key which reads the header it sets.
+	Key AdmissionKeyFunc
+}
+
+// WithAdmissionLimits limit the websocket connections the engine accepts.
+// Connections over the total limit are rejected with a 503 and connections
+// over the per key limit with a 429.
+func WithAdmissionLimits(limits AdmissionLimits) EngineConfig {
+	return func(e *Engine) error {
+		if limits.Key == nil {
+			limits.Key = remoteIPKey
+		}
+		e.admissionLimits = limits
+		return nil
+	}
+}
+
+// ConnectionCounts returns the number of websocket connections currently
+// accepted by the engine, in total and by admission key.
+func (e *Engine) ConnectionCounts() (int, map[string]int) {
+	e.admissions.mu.Lock()
+	defer e.admissions.mu.Unlock()
+	return e.admissions.total, maps.Clone(e.admissions.perKey)
+}
+
+// admissions counts the websocket connections of an engine.
+type admissions struct {
+	mu     sync.Mutex
+	total  int
+	perKey map[string]int
+}
+
+// admit a websocket connection made by the principal if it is within the
+// limits. The returned key must be released when the connection closes.
+func (e *Engine) admit(r *http.Request, principal any) (string, error) {
+	limits := e.admissionLimits
+	key := remoteIP(r)
+	if limits.Key != nil {
+		key = limits.Key(r, principal)
+	}
+
+	a := &e.admissions
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if limits.MaxSocketsPerKey > 0 && a.perKey[key] >= limits.MaxSocketsPerKey {
+		return "", ErrMaxSocketsPerKey
+	}
+	if limits.MaxSockets > 0 && a.total >= limits.MaxSockets {
+		return "", ErrMaxSockets
+	}
+	if a.perKey == nil {
+		a.perKey = map[string]int{}
+	}
+	a.total++
+	a.perKey[key]++
+	return key, nil
+}
+
+// release a connection admitted with the key.
+func (e *Engine) release(key string) {
+	a := &e.admissions
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.total--
+	a.perKey[key]--
+	if a.perKey[key] <= 0 {
+		delete(a.perKey, key)
+	}
+}
+
+// admissionStatus the HTTP status to reject a connection with.
+func admissionStatus(err error) int {
+	if errors.Is(err, ErrMaxSocketsPerKey) {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusServiceUnavailable
+}
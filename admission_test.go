@@ -0,0 +1,54 @@
+package live
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdmission(t *testing.T) {
+	e := NewHttpHandler(context.Background(), NewHandler(), WithAuthenticator(&testAuthenticator{}), WithAdmissionLimits(AdmissionLimits{
+		MaxSockets:       3,
+		MaxSocketsPerKey: 2,
+		Key: func(r *http.Request, principal any) string {
+			return principal.(string)
+		},
+	}))
+
+	request := func(user string) *http.Request {
+		req := httptest.NewRequest("GET", "/?"+cookieSocketID+"=abc", nil)
+		req.Header.Set("X-User", user)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		return req
+	}
+
+	for _, user := range []string{"a", "a", "b"} {
+		if _, err := e.admit(request(user), user); err != nil {
+			t.Fatalf("expected %s to be admitted: %s", user, err)
+		}
+	}
+
+	for user, status := range map[string]int{"a": http.StatusTooManyRequests, "c": http.StatusServiceUnavailable} {
+		rr := httptest.NewRecorder()
+		e.ServeHTTP(rr, request(user))
+		if rr.Code != status {
+			t.Errorf("%s: expected status %d, got %d", user, status, rr.Code)
+		}
+	}
+
+	total, perKey := e.ConnectionCounts()
+	if total != 3 || perKey["a"] != 2 || perKey["b"] != 1 {
+		t.Errorf("unexpected counts %d %v", total, perKey)
+	}
+
+	e.release("b")
+	if _, err := e.admit(request("c"), "c"); err != nil {
+		t.Errorf("expected c to be admitted after release: %s", err)
+	}
+	total, perKey = e.ConnectionCounts()
+	if total != 3 || perKey["b"] != 0 || perKey["c"] != 1 {
+		t.Errorf("unexpected counts %d %v", total, perKey)
+	}
+}
@@ -0,0 +1,173 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// AsyncState the state of a task started with Socket.StartAsync.
+type AsyncState int
+
+const (
+	// AsyncNone the task has not been started.
+	AsyncNone AsyncState = iota
+	// AsyncLoading the task is running.
+	AsyncLoading
+	// AsyncOK the task completed successfully.
+	AsyncOK
+	// AsyncFailed the task returned an error.
+	AsyncFailed
+)
+
+// AsyncFunc loads data off the event loop. The context is cancelled if the
+// socket is unmounted, or the task is started again.
+type AsyncFunc func(ctx context.Context) (any, error)
+
+// AsyncResult the state of an async task, available to render from the
+// RenderContext, for example
+//
+//	{{ with index .Async "users" }}
+//	    {{ if .Loading }}Loading...{{ end }}
+//	    {{ if .Failed }}{{ .Err }}{{ end }}
+//	    {{ if .OK }}{{ range .Result }}...{{ end }}{{ end }}
+//	{{ end }}
+type AsyncResult struct {
+	State AsyncState
+	// Result returned by the task once it is OK.
+	Result any
+	// Err returned by the task once it has Failed.
+	Err error
+}
+
+// Loading is the task running.
+func (a AsyncResult) Loading() bool { return a.State == AsyncLoading }
+
+// OK did the task complete successfully.
+func (a AsyncResult) OK() bool { return a.State == AsyncOK }
+
+// Failed did the task return an error.
+func (a AsyncResult) Failed() bool { return a.State == AsyncFailed }
+
+// asyncTask a running async task.
+type asyncTask struct {
+	result AsyncResult
+	cancel context.CancelFunc
+}
+
+// asyncDone the outcome of an async task, to be applied on the sockets
+// event loop.
+type asyncDone struct {
+	name   string
+	task   *asyncTask
+	result any
+	err    error
+}
+
+// StartAsync runs fn off the event loop, so that slow loads don't hold up
+// mount or event handlers. Until fn returns the task is loading. Once it
+// returns its result is merged into the sockets assigns by the HandleSelf
+// handler of the same name and the socket is rendered. Without a HandleSelf
+// handler of the same name only the tasks state in Async changes, and a
+// warning is logged when the task is started.
+//
+//	h.HandleSelf("users", func(ctx context.Context, s *live.Socket, d any) (any, error) {
+//	    m := s.Assigns().(*model)
+//	    m.Users = d.([]User)
+//	    return m, nil
+//	})
+//
+// Starting a task with the same name cancels the previous one. Tasks are
+// only run once the socket is connected, and are cancelled when it is
+// unmounted.
+func (s *Socket) StartAsync(name string, fn AsyncFunc) {
+	if _, err := s.engine.socketHandler(s).getSelf(name); err != nil {
+		slog.Warn("async task has no self handler", "socket", s.id, "task", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if prev, ok := s.async[name]; ok && prev.cancel != nil {
+		prev.cancel()
+	}
+	if s.async == nil {
+		s.async = map[string]*asyncTask{}
+	}
+	task := &asyncTask{result: AsyncResult{State: AsyncLoading}}
+	s.async[name] = task
+	if !s.connected {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(s.mountCtx)
+	task.cancel = cancel
+	go func() {
+		defer cancel()
+		result, err := s.engine.callAsync(ctx, s, fn)
+		if ctx.Err() != nil {
+			return
+		}
+		done := &asyncDone{name: name, task: task, result: result, err: err}
+		if err := s.selfOp(ctx, socketSelfOp{Event: Event{T: name}, async: done}); err != nil {
+			slog.Debug("async task not delivered", "socket", s.id, "task", name, "err", err)
+		}
+	}()
+}
+
+// Async returns the state of the sockets async tasks, by name.
+func (s *Socket) Async() map[string]AsyncResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]AsyncResult, len(s.async))
+	for name, task := range s.async {
+		out[name] = task.result
+	}
+	return out
+}
+
+// finishAsync records the outcome of a task, returning false if the task
+// has since been restarted or cancelled.
+func (s *Socket) finishAsync(done *asyncDone) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.async[done.name] != done.task {
+		return false
+	}
+	if done.err != nil {
+		done.task.result = AsyncResult{State: AsyncFailed, Err: done.err}
+	} else {
+		done.task.result = AsyncResult{State: AsyncOK, Result: done.result}
+	}
+	return true
+}
+
+// callAsync runs an async task.
+func (e *Engine) callAsync(ctx context.Context, sock *Socket, fn AsyncFunc) (result any, err error) {
+	defer e.recoverPanic(ctx, sock, &err)
+	return fn(ctx)
+}
+
+// handleAsync applies the outcome of an async task to the socket, merging a
+// successful result into its assigns.
+func (e *Engine) handleAsync(ctx context.Context, sock *Socket, done *asyncDone) {
+	if err := e.hasSocket(sock); err != nil {
+		return
+	}
+	if !sock.finishAsync(done) {
+		return
+	}
+	msg := Event{T: done.name, SelfData: done.result}
+	render := true
+	if done.err == nil {
+		var err error
+		if render, err = e.handleSelf(ctx, done.name, sock, msg); err != nil && !errors.Is(err, ErrNoEventHandler) {
+			slog.Error("async task error", "err", err)
+			e.reportPanic(sock, msg, err)
+		}
+	} else {
+		slog.Warn("async task failed", "socket", sock.ID(), "task", done.name, "err", done.err)
+		e.reportPanic(sock, msg, done.err)
+	}
+	if render {
+		e.renderEmitted(ctx, sock, msg)
+	}
+}
@@ -0,0 +1,78 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/coder/websocket"
+)
+
+func TestStartAsync(t *testing.T) {
+	release := make(chan struct{})
+	h := testHandler()
+	h.MountHandler = func(ctx context.Context, s *Socket) (any, error) {
+		s.StartAsync("data", func(ctx context.Context) (any, error) {
+			<-release
+			return "loaded", nil
+		})
+		return nil, nil
+	}
+	h.HandleSelf("data", func(ctx context.Context, s *Socket, d any) (any, error) {
+		return d, nil
+	})
+	_, sock, _ := testConnect(t, h, "async")
+	eventually(t, func() bool { return sock.Async()["data"].Loading() })
+
+	close(release)
+	eventually(t, func() bool { return sock.Async()["data"].OK() })
+	if sock.Assigns() != "loaded" {
+		t.Errorf("expected result to be merged into assigns, got %v", sock.Assigns())
+	}
+}
+
+func TestStartAsyncFailed(t *testing.T) {
+	failed := errors.New("failed")
+	h := testHandler()
+	h.MountHandler = func(ctx context.Context, s *Socket) (any, error) {
+		s.StartAsync("data", func(ctx context.Context) (any, error) {
+			return nil, failed
+		})
+		return nil, nil
+	}
+	h.HandleSelf("data", func(ctx context.Context, s *Socket, d any) (any, error) {
+		return d, nil
+	})
+	_, sock, _ := testConnect(t, h, "async")
+	eventually(t, func() bool { return sock.Async()["data"].Failed() })
+	if !errors.Is(sock.Async()["data"].Err, failed) {
+		t.Errorf("expected task error, got %v", sock.Async()["data"].Err)
+	}
+	if sock.Assigns() != nil {
+		t.Errorf("expected assigns to be unchanged, got %v", sock.Assigns())
+	}
+}
+
+func TestStartAsyncCancelledOnUnmount(t *testing.T) {
+	cancelled := make(chan struct{})
+	h := testHandler()
+	h.MountHandler = func(ctx context.Context, s *Socket) (any, error) {
+		s.StartAsync("data", func(ctx context.Context) (any, error) {
+			<-ctx.Done()
+			close(cancelled)
+			return nil, ctx.Err()
+		})
+		return nil, nil
+	}
+	_, _, c := testConnect(t, h, "async")
+	c.Close(websocket.StatusNormalClosure, "")
+
+	eventually(t, func() bool {
+		select {
+		case <-cancelled:
+			return true
+		default:
+			return false
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package live
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"time"
+)
+
+// Authenticator identifies who a socket belongs to.
+type Authenticator interface {
+	// Authenticate a request, returning the principal, for example the user,
+	// which made it. This is called on the initial GET request, websocket
+	// upgrade and uploads. Returning an error rejects the request.
+	Authenticate(ctx context.Context, r *http.Request) (any, error)
+	// Revalidate checks that a principal is still allowed to use the socket,
+	// for example that their session hasn't been revoked. Returning an error
+	// ends the socket.
+	Revalidate(ctx context.Context, principal any) error
+}
+
+// PrincipalComparer is optionally implemented by an Authenticator to decide if
+// two principals are the same, for example by comparing user IDs. Principals
+// are compared when a request is made for an existing socket. Without it they
+// are compared with reflect.DeepEqual, which fails for principals carrying
+// anything that changes from request to request.
+type PrincipalComparer interface {
+	Equal(a, b any) bool
+}
+
+// WithAuthenticator set the engines authenticator. The principal it returns is
+// available from Socket.Principal.
+func WithAuthenticator(a Authenticator) EngineConfig {
+	return func(e *Engine) error {
+		e.authenticator = a
+		return nil
+	}
+}
+
+// WithRevalidation revalidate the principal of each connected socket at the
+// given interval. If revalidation fails the client is redirected to the given
+// URL, or if it is nil the socket is closed.
+func WithRevalidation(interval time.Duration, redirect *url.URL) EngineConfig {
+	return func(e *Engine) error {
+		if interval <= 0 {
+			return fmt.Errorf("revalidation interval must be positive")
+		}
+		e.revalidateInterval = interval
+		e.revokedRedirect = redirect
+		return nil
+	}
+}
+
+// authenticate a request, if the engine has an authenticator.
+func (e *Engine) authenticate(ctx context.Context, r *http.Request) (any, error) {
+	if e.authenticator == nil {
+		return nil, nil
+	}
+	principal, err := e.authenticator.Authenticate(ctx, r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	}
+	return principal, nil
+}
+
+// authenticateSocket authenticates a request for an existing socket, checking
+// that it was made by the same principal that the socket belongs to.
+func (e *Engine) authenticateSocket(ctx context.Context, r *http.Request, sock *Socket) error {
+	principal, err := e.authenticate(ctx, r)
+	if err != nil {
+		return err
+	}
+	if !e.samePrincipal(principal, sock.Principal()) {
+		return fmt.Errorf("%w: principal does not match socket", ErrUnauthorized)
+	}
+	return nil
+}
+
+// samePrincipal returns if two principals are the same.
+func (e *Engine) samePrincipal(a, b any) bool {
+	if c, ok := e.authenticator.(PrincipalComparer); ok {
+		return c.Equal(a, b)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// revalidate the principal of a socket.
+func (e *Engine) revalidate(ctx context.Context, sock *Socket) error {
+	if err := e.authenticator.Revalidate(ctx, sock.Principal()); err != nil {
+		return fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	}
+	return nil
+}
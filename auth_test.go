@@ -0,0 +1,228 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+type testAuthenticator struct {
+	revoked atomic.Bool
+}
+
+func (a *testAuthenticator) Authenticate(ctx context.Context, r *http.Request) (any, error) {
+	user := r.Header.Get("X-User")
+	if user == "" {
+		return nil, errors.New("no user")
+	}
+	return user, nil
+}
+
+func (a *testAuthenticator) Revalidate(ctx context.Context, principal any) error {
+	if a.revoked.Load() {
+		return errors.New("revoked")
+	}
+	return nil
+}
+
+func TestAuthenticatorGet(t *testing.T) {
+	var principal atomic.Value
+	h := testHandler()
+	h.MountHandler = func(ctx context.Context, s *Socket) (any, error) {
+		principal.Store(s.Principal())
+		return nil, nil
+	}
+	e := NewHttpHandler(context.Background(), h, WithAuthenticator(&testAuthenticator{}))
+
+	rr := httptest.NewRecorder()
+	e.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-User", "alice")
+	rr = httptest.NewRecorder()
+	e.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if principal.Load() != "alice" {
+		t.Fatalf("expected principal alice, got %v", principal.Load())
+	}
+}
+
+func TestAuthenticatorRevalidation(t *testing.T) {
+	auth := &testAuthenticator{}
+	redirect := &url.URL{Path: "/login"}
+	_, wsURL := testServer(t, testHandler(),
+		WithAuthenticator(auth),
+		WithRevalidation(10*time.Millisecond, redirect),
+	)
+
+	cookie := cookieSocketID + "=auth"
+	if _, resp, err := websocket.Dial(context.Background(), wsURL, &websocket.DialOptions{
+		HTTPHeader: http.Header{"Cookie": []string{cookie}},
+	}); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected unauthenticated upgrade to be rejected, got %v", err)
+	}
+
+	c, _, err := websocket.Dial(context.Background(), wsURL, &websocket.DialOptions{
+		HTTPHeader: http.Header{"Cookie": []string{cookie}, "X-User": []string{"alice"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.CloseNow()
+	if ev := testRead(t, c); ev.T != EventConnect {
+		t.Fatalf("expected connect event, got %s", ev.T)
+	}
+
+	auth.revoked.Store(true)
+	ev := testRead(t, c)
+	for ev.T == EventPatch {
+		ev = testRead(t, c)
+	}
+	if ev.T != EventRedirect {
+		t.Fatalf("expected redirect event, got %s", ev.T)
+	}
+	var to string
+	if err := json.Unmarshal(ev.Data, &to); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(to, "/login") {
+		t.Fatalf("expected redirect to /login, got %s", to)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, _, err := c.Read(ctx); websocket.CloseStatus(err) != websocket.StatusPolicyViolation {
+		t.Fatalf("expected policy violation close, got %v", err)
+	}
+}
+
+// testSession a principal which changes from request to request.
+type testSession struct {
+	User string
+	Seen time.Time
+}
+
+// sessionAuthenticator compares sessions by user.
+type sessionAuthenticator struct {
+	testAuthenticator
+}
+
+func (a *sessionAuthenticator) Authenticate(ctx context.Context, r *http.Request) (any, error) {
+	return &testSession{User: r.Header.Get("X-User"), Seen: time.Now()}, nil
+}
+
+func (a *sessionAuthenticator) Equal(x, y any) bool {
+	return x.(*testSession).User == y.(*testSession).User
+}
+
+func TestAuthenticatorEqual(t *testing.T) {
+	e := NewHttpHandler(context.Background(), testHandler(), WithAuthenticator(&sessionAuthenticator{}))
+	sock := NewSocket(context.Background(), e, "session")
+	defer sock.close()
+	sock.setPrincipal(&testSession{User: "alice", Seen: time.Now().Add(-time.Hour)})
+
+	for user, ok := range map[string]bool{"alice": true, "bob": false} {
+		req := httptest.NewRequest("POST", "/", nil)
+		req.Header.Set("X-User", user)
+		err := e.authenticateSocket(context.Background(), req, sock)
+		if ok && err != nil {
+			t.Errorf("%s: expected the same user to match, got %v", user, err)
+		}
+		if !ok && !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("%s: expected another user to be unauthorized, got %v", user, err)
+		}
+	}
+}
+
+func TestAuthenticatorAnotherEngine(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := NewMemorySocketStateStore(ctx)
+
+	h := testHandler()
+	h.MountHandler = func(ctx context.Context, s *Socket) (any, error) {
+		return s.Assigns(), nil
+	}
+	h.HandleEvent("secret", func(ctx context.Context, s *Socket, p Params) (any, error) {
+		return "secret of " + s.Principal().(string), nil
+	})
+	auth := WithAuthenticator(&testAuthenticator{})
+	first, firstURL := testServer(t, h, auth, WithSocketStateStore(store), WithDisconnectGracePeriod(time.Minute))
+	second, secondURL := testServer(t, h, auth, WithSocketStateStore(store))
+
+	dial := func(url, user string) (*websocket.Conn, error) {
+		c, _, err := websocket.Dial(context.Background(), url, &websocket.DialOptions{
+			HTTPHeader: http.Header{"Cookie": []string{cookieSocketID + "=shared"}, "X-User": []string{user}},
+		})
+		return c, err
+	}
+	rejected := func(c *websocket.Conn) {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		for {
+			_, d, err := c.Read(ctx)
+			if err != nil {
+				if errors.Is(err, context.DeadlineExceeded) {
+					t.Fatal("expected socket to be closed")
+				}
+				return
+			}
+			if strings.Contains(string(d), "secret of alice") {
+				t.Fatal("expected another principals assigns not to be sent")
+			}
+		}
+	}
+
+	alice, err := dial(firstURL, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testWrite(t, alice, Event{T: "secret", ID: 1})
+	for ev := testRead(t, alice); ev.T != EventAck; ev = testRead(t, alice) {
+	}
+
+	bob, err := dial(firstURL, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejected(bob)
+
+	// Once alice has gone, bob tries the engine which doesn't hold her
+	// socket.
+	alice.Close(websocket.StatusNormalClosure, "")
+	eventually(t, func() bool {
+		s, err := first.GetSocket("shared")
+		return err == nil && s.Detached()
+	})
+	bob, err = dial(secondURL, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejected(bob)
+	if _, err := second.GetSocket("shared"); err == nil {
+		t.Error("expected the other engine not to take the socket")
+	}
+
+	req := httptest.NewRequest("POST", "/", nil)
+	req.AddCookie(&http.Cookie{Name: cookieSocketID, Value: "shared"})
+	req.Header.Set("X-User", "bob")
+	rr := httptest.NewRecorder()
+	second.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected upload by another principal to be unauthorized, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,221 @@
+package live
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// bindingValueKey valid `live-value-*` keys. Attribute names are lower cased
+// by the browser so only lower case keys make it to the server unchanged.
+var bindingValueKey = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// Binding an event binding, which renders to the attributes of an element.
+//
+//	live.Click("inc").Value("id", 3).Debounce(200)
+//
+// renders
+//
+//	live-click="inc" live-value-id="3" live-debounce="200"
+type Binding struct {
+	attr     string
+	event    string
+	values   [][2]string
+	debounce string
+	key      string
+}
+
+// BindingOption modifies a binding, used by the template functions.
+type BindingOption func(Binding) Binding
+
+// Click binds an event to clicks.
+func Click(event string) Binding { return Binding{attr: "live-click", event: event} }
+
+// Contextmenu binds an event to context menu clicks.
+func Contextmenu(event string) Binding { return Binding{attr: "live-contextmenu", event: event} }
+
+// Mousedown binds an event to mouse down.
+func Mousedown(event string) Binding { return Binding{attr: "live-mousedown", event: event} }
+
+// Mouseup binds an event to mouse up.
+func Mouseup(event string) Binding { return Binding{attr: "live-mouseup", event: event} }
+
+// Focus binds an event to the element gaining focus.
+func Focus(event string) Binding { return Binding{attr: "live-focus", event: event} }
+
+// Blur binds an event to the element losing focus.
+func Blur(event string) Binding { return Binding{attr: "live-blur", event: event} }
+
+// WindowFocus binds an event to the window gaining focus.
+func WindowFocus(event string) Binding { return Binding{attr: "live-window-focus", event: event} }
+
+// WindowBlur binds an event to the window losing focus.
+func WindowBlur(event string) Binding { return Binding{attr: "live-window-blur", event: event} }
+
+// Keydown binds an event to key down on the element.
+func Keydown(event string) Binding { return Binding{attr: "live-keydown", event: event} }
+
+// Keyup binds an event to key up on the element.
+func Keyup(event string) Binding { return Binding{attr: "live-keyup", event: event} }
+
+// WindowKeydown binds an event to key down anywhere in the window.
+func WindowKeydown(event string) Binding { return Binding{attr: "live-window-keydown", event: event} }
+
+// WindowKeyup binds an event to key up anywhere in the window.
+func WindowKeyup(event string) Binding { return Binding{attr: "live-window-keyup", event: event} }
+
+// Change binds an event to a form changing.
+func Change(event string) Binding { return Binding{attr: "live-change", event: event} }
+
+// Submit binds an event to a form submitting.
+func Submit(event string) Binding { return Binding{attr: "live-submit", event: event} }
+
+// Value adds a `live-value-*` param which is sent with the event. Keys must be
+// lower case letters, numbers, dashes and underscores, any other key is ignored
+// with a warning. The liveValue template function fails the template instead.
+func (b Binding) Value(key string, value any) Binding {
+	if !bindingValueKey.MatchString(key) {
+		slog.Warn("binding value key invalid", "event", b.event, "key", key)
+		return b
+	}
+	values := make([][2]string, len(b.values), len(b.values)+1)
+	copy(values, b.values)
+	b.values = append(values, [2]string{key, fmt.Sprint(value)})
+	return b
+}
+
+// Debounce waits until the event has stopped firing for the milliseconds
+// before sending it.
+func (b Binding) Debounce(ms int) Binding {
+	b.debounce = strconv.Itoa(ms)
+	return b
+}
+
+// DebounceBlur waits until the element loses focus before sending the event.
+func (b Binding) DebounceBlur() Binding {
+	b.debounce = "blur"
+	return b
+}
+
+// Key only sends a key event for the key, for example "Enter".
+func (b Binding) Key(key string) Binding {
+	b.key = key
+	return b
+}
+
+// String renders the bindings attributes, escaped.
+func (b Binding) String() string {
+	var out strings.Builder
+	attr := func(name, value string) {
+		if out.Len() > 0 {
+			out.WriteByte(' ')
+		}
+		fmt.Fprintf(&out, `%s="%s"`, name, html.EscapeString(value))
+	}
+	attr(b.attr, b.event)
+	for _, v := range b.values {
+		attr("live-value-"+v[0], v[1])
+	}
+	if b.debounce != "" {
+		attr("live-debounce", b.debounce)
+	}
+	if b.key != "" {
+		attr("live-key", b.key)
+	}
+	return out.String()
+}
+
+// Attr renders the bindings attributes for use in an html/template, for
+// example `<button {{ .Inc.Attr }}>`.
+func (b Binding) Attr() template.HTMLAttr {
+	return template.HTMLAttr(b.String())
+}
+
+// EventResolver maps an event name used in a template to the event that is
+// bound, returning an error if the event can't be handled.
+type EventResolver func(event string) (string, error)
+
+// TemplateFuncs the binding helpers as template functions. The template must
+// be parsed with them, for example
+//
+//	template.New("").Funcs(live.TemplateFuncs()).Parse(`
+//	    <button {{ liveClick "inc" (liveValue "id" .ID) (liveDebounce 200) }}>+</button>
+//	`)
+//
+// An event can also be JSCommands. When used with WithTemplateRenderer events
+// are checked against the handlers events as the template renders.
+func TemplateFuncs() template.FuncMap {
+	return BindingFuncs(nil)
+}
+
+// BindingFuncs the binding helpers as template functions, with event names
+// passed through the resolver. A nil resolver uses event names as they are.
+func BindingFuncs(resolve EventResolver) template.FuncMap {
+	bind := func(constructor func(string) Binding) func(any, ...BindingOption) (template.HTMLAttr, error) {
+		return func(event any, options ...BindingOption) (template.HTMLAttr, error) {
+			var name string
+			switch e := event.(type) {
+			case string:
+				name = e
+				if resolve != nil {
+					var err error
+					if name, err = resolve(e); err != nil {
+						return "", err
+					}
+				}
+			case JSCommands:
+				name = e.String()
+			default:
+				return "", fmt.Errorf("binding event must be a string or JSCommands, got %T", event)
+			}
+			b := constructor(name)
+			for _, o := range options {
+				b = o(b)
+			}
+			return b.Attr(), nil
+		}
+	}
+	return template.FuncMap{
+		"liveClick":         bind(Click),
+		"liveContextmenu":   bind(Contextmenu),
+		"liveMousedown":     bind(Mousedown),
+		"liveMouseup":       bind(Mouseup),
+		"liveFocus":         bind(Focus),
+		"liveBlur":          bind(Blur),
+		"liveWindowFocus":   bind(WindowFocus),
+		"liveWindowBlur":    bind(WindowBlur),
+		"liveKeydown":       bind(Keydown),
+		"liveKeyup":         bind(Keyup),
+		"liveWindowKeydown": bind(WindowKeydown),
+		"liveWindowKeyup":   bind(WindowKeyup),
+		"liveChange":        bind(Change),
+		"liveSubmit":        bind(Submit),
+		"liveValue": func(key string, value any) (BindingOption, error) {
+			if !bindingValueKey.MatchString(key) {
+				return nil, fmt.Errorf("binding value key %q invalid, keys must be lower case letters, numbers, dashes and underscores", key)
+			}
+			return func(b Binding) Binding { return b.Value(key, value) }, nil
+		},
+		"liveDebounce": func(ms int) BindingOption {
+			return func(b Binding) Binding { return b.Debounce(ms) }
+		},
+		"liveDebounceBlur": func() BindingOption {
+			return func(b Binding) Binding { return b.DebounceBlur() }
+		},
+		"liveKey": func(key string) BindingOption {
+			return func(b Binding) Binding { return b.Key(key) }
+		},
+	}
+}
+
+// ResolveEvent checks that the handler has an event handler for the event.
+func (h *Handler) ResolveEvent(event string) (string, error) {
+	if _, err := h.getEvent(event); err != nil {
+		return "", err
+	}
+	return event, nil
+}
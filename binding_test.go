@@ -0,0 +1,59 @@
+package live
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html/template"
+	"testing"
+)
+
+func TestBinding(t *testing.T) {
+	b := Click("inc").Value("id", 3).Value("Bad Key", 1).Debounce(200)
+	if got, want := b.String(), `live-click="inc" live-value-id="3" live-debounce="200"`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	k := Keyup("search").Key("Enter").Value("q", `"><script>`).DebounceBlur()
+	if got, want := k.String(), `live-keyup="search" live-value-q="&#34;&gt;&lt;script&gt;" live-debounce="blur" live-key="Enter"`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestBindingTemplateFuncs(t *testing.T) {
+	tmpl := template.Must(template.New("").Funcs(TemplateFuncs()).Parse(
+		`<button {{ liveClick "inc" (liveValue "id" .) (liveDebounce 200) }}></button>`,
+	))
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, 3); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), `<button live-click="inc" live-value-id="3" live-debounce="200"></button>`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestBindingTemplateRendererChecksEvents(t *testing.T) {
+	tmpl := template.Must(template.New("").Funcs(TemplateFuncs()).Parse(
+		`<button {{ liveClick "inc" }}></button>`,
+	))
+	h := NewHandler(WithTemplateRenderer(tmpl))
+	if _, err := h.RenderHandler(context.Background(), &RenderContext{}); !errors.Is(err, ErrNoEventHandler) {
+		t.Errorf("expected missing event handler error, got %v", err)
+	}
+	h.HandleEvent("inc", func(ctx context.Context, s *Socket, p Params) (any, error) {
+		return nil, nil
+	})
+	if _, err := h.RenderHandler(context.Background(), &RenderContext{}); err != nil {
+		t.Errorf("expected render, got %v", err)
+	}
+}
+
+func TestBindingTemplateInvalidValueKey(t *testing.T) {
+	tmpl := template.Must(template.New("").Funcs(TemplateFuncs()).Parse(
+		`<button {{ liveClick "inc" (liveValue "Bad Key" .) }}></button>`,
+	))
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, 3); err == nil {
+		t.Errorf("expected invalid value key to fail the template, got %s", buf.String())
+	}
+}
@@ -0,0 +1,98 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// call the payload of a call event.
+type call struct {
+	Hook    string `json:"hook"`
+	Event   string `json:"event"`
+	Payload any    `json:"payload,omitempty"`
+}
+
+// callReply the payload of a reply event.
+type callReply struct {
+	Reply json.RawMessage `json:"reply,omitempty"`
+	Err   string          `json:"err,omitempty"`
+}
+
+// Call sends an event to the hook of the element with the given id, and waits
+// for the hooks reply. The hook handles it with `this.handleCall(event, cb)`.
+// Call blocks until the reply arrives, the context is done or the socket is
+// closed, so the context should have a timeout.
+func (s *Socket) Call(ctx context.Context, hookID, event string, payload any) (json.RawMessage, error) {
+	if !s.Connected() {
+		return nil, ErrNotConnected
+	}
+
+	ID, reply := s.startCall()
+	defer s.endCall(ID)
+
+	if err := s.Send(EventCall, call{Hook: hookID, Event: event, Payload: payload}, WithID(ID)); err != nil {
+		return nil, fmt.Errorf("could not send call: %w", err)
+	}
+
+	select {
+	case r := <-reply:
+		if r.Err != "" {
+			return nil, fmt.Errorf("%w: %s", ErrCallFailed, r.Err)
+		}
+		return r.Reply, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-s.ctx.Done():
+		return nil, ErrNoSocket
+	}
+}
+
+// startCall registers a call waiting on a reply.
+func (s *Socket) startCall() (int, chan callReply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.calls == nil {
+		s.calls = map[int]chan callReply{}
+	}
+	s.callID++
+	reply := make(chan callReply, 1)
+	s.calls[s.callID] = reply
+	// Keep reading from the websocket, the reply may be behind events
+	// waiting to be handled.
+	select {
+	case s.callStarted <- struct{}{}:
+	default:
+	}
+	return s.callID, reply
+}
+
+// calling returns if the socket is waiting on the reply to a call.
+func (s *Socket) calling() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.calls) > 0
+}
+
+// endCall stops waiting on a call.
+func (s *Socket) endCall(ID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.calls, ID)
+}
+
+// resolveCall passes a reply from the client to the call waiting on it.
+// Replies to calls which are no longer waiting are dropped.
+func (s *Socket) resolveCall(msg Event) {
+	var r callReply
+	if err := json.Unmarshal(msg.Data, &r); err != nil {
+		r = callReply{Err: ErrMessageMalformed.Error()}
+	}
+	s.mu.Lock()
+	reply, ok := s.calls[msg.ID]
+	delete(s.calls, msg.ID)
+	s.mu.Unlock()
+	if ok {
+		reply <- r
+	}
+}
@@ -10,6 +10,9 @@ type contextKey string
 const (
 	requestKey contextKey = "context_request"
 	writerKey  contextKey = "context_writer"
+
+	socketIDKey  contextKey = "context_socket_id"
+	principalKey contextKey = "context_principal"
 )
 
 // contextWithRequest embed the initiating request within the context.
@@ -41,3 +44,16 @@ func Writer(ctx context.Context) http.ResponseWriter {
 	}
 	return w
 }
+
+// SocketIDFromContext pulls out the ID of the socket a context from
+// Socket.Context belongs to.
+func SocketIDFromContext(ctx context.Context) (SocketID, bool) {
+	id, ok := ctx.Value(socketIDKey).(SocketID)
+	return id, ok
+}
+
+// PrincipalFromContext pulls out the principal of the socket a context from
+// Socket.Context belongs to.
+func PrincipalFromContext(ctx context.Context) any {
+	return ctx.Value(principalKey)
+}
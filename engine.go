@@ -8,8 +8,10 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"slices"
@@ -32,7 +34,12 @@ func WithWebsocketAcceptOptions(options *websocket.AcceptOptions) EngineConfig {
 	}
 }
 
-// WithSocketStateStore set the engines socket state store.
+// WithSocketStateStore set the engines socket state store. Engines sharing a
+// store can resume each others sockets. Only what is in the SocketState moves
+// with the socket, it is mounted again on the engine it moves to, so that
+// uploads, timers and async tasks can be set up again. Anything else held by
+// the socket, such as messages queued while it was detached and calls
+// waiting on a reply, stays with the engine it left.
 func WithSocketStateStore(sss SocketStateStore) EngineConfig {
 	return func(e *Engine) error {
 		e.socketStateStore = sss
@@ -48,6 +55,35 @@ func WithWebsocketMaxMessageSize(n int64) EngineConfig {
 	}
 }
 
+// WithDisconnectGracePeriod keep a socket, its state and any messages sent to
+// it, for the given duration after its websocket disconnects. If the client
+// reconnects with the same socket ID within this period the socket is resumed
+// rather than mounted again.
+func WithDisconnectGracePeriod(d time.Duration) EngineConfig {
+	return func(e *Engine) error {
+		if d < 0 {
+			return fmt.Errorf("disconnect grace period must not be negative")
+		}
+		e.DisconnectGracePeriod = d
+		return nil
+	}
+}
+
+// WithRenderInterval batch the renders of a socket caused by server side
+// events, such as Self, broadcasts, async tasks and upload progress, so that
+// a socket is rendered at most once per interval. Changes within the interval
+// are sent to the client as one set of patches. Client events are still
+// rendered straight away.
+func WithRenderInterval(d time.Duration) EngineConfig {
+	return func(e *Engine) error {
+		if d < 0 {
+			return fmt.Errorf("render interval must not be negative")
+		}
+		e.RenderInterval = d
+		return nil
+	}
+}
+
 // BroadcastHandler a way for processes to communicate.
 type BroadcastHandler func(ctx context.Context, e *Engine, msg Event)
 
@@ -61,6 +97,12 @@ type Engine struct {
 	// broadcast handle a broadcast.
 	BroadcastHandler BroadcastHandler
 
+	// PanicHandler reports panics recovered from handlers. Defaults to logging
+	// the panic and its stack trace.
+	PanicHandler PanicHandler
+	// PanicPolicy what to do with a socket after a handler panics.
+	PanicPolicy PanicPolicy
+
 	// socket handling channels.
 	addSocketC      chan engineAddSocket
 	getSocketC      chan engineGetSocket
@@ -81,8 +123,36 @@ type Engine struct {
 	// too the default OS temp directory.
 	UploadStagingLocation string
 
+	// DisconnectGracePeriod how long to keep a socket once its websocket disconnects
+	// before unmounting it. Defaults to 0, unmounting straight away.
+	DisconnectGracePeriod time.Duration
+
+	// RenderInterval the least time between renders of a socket caused by
+	// server side events. Defaults to 0, rendering after every event.
+	RenderInterval time.Duration
+
+	// id identifies this engine as the owner of socket state.
+	id string
+
 	acceptOptions    *websocket.AcceptOptions
 	socketStateStore SocketStateStore
+	socketIDSigner   *SocketIDSigner
+	socketCookie     http.Cookie
+	allowedOrigins   []string
+	checkOrigins     bool
+	csrfKey          []byte
+	eventLimits      EventLimits
+	ipLimiters       *ipLimiters
+	admissionLimits  AdmissionLimits
+	admissions       admissions
+
+	router   *Router
+	flashKey []byte
+	outbound OutboundQueue
+
+	authenticator      Authenticator
+	revalidateInterval time.Duration
+	revokedRedirect    *url.URL
 }
 
 type engineAddSocket struct {
@@ -142,6 +212,8 @@ func NewHttpHandler(ctx context.Context, h *Handler, configs ...EngineConfig) *E
 		BroadcastHandler: func(ctx context.Context, h *Engine, msg Event) {
 			h.self(ctx, nil, msg)
 		},
+		PanicHandler:         defaultPanicHandler,
+		id:                   NewID(),
 		IgnoreFaviconRequest: true,
 		MaxUploadSize:        maxUploadSize,
 		MaxMessageSize:       32768,
@@ -150,15 +222,29 @@ func NewHttpHandler(ctx context.Context, h *Handler, configs ...EngineConfig) *E
 		getSocketC:           make(chan engineGetSocket),
 		deleteSocketC:        make(chan engineDeleteSocket),
 		iterateSocketsC:      make(chan engineIterateSockets),
+		socketCookie:         defaultSocketCookie(),
 	}
 	for _, conf := range configs {
 		if err := conf(e); err != nil {
 			slog.Warn(fmt.Sprintf("could not apply config to engine: %s", err))
 		}
 	}
+	if e.flashKey == nil {
+		e.flashKey = e.defaultFlashKey(e.socketStateStore != nil)
+	}
 	if e.socketStateStore == nil {
 		e.socketStateStore = NewMemorySocketStateStore(ctx)
 	}
+	// The websocket accept also checks the origin, so make sure it
+	// agrees with the engine.
+	if e.checkOrigins {
+		options := websocket.AcceptOptions{}
+		if e.acceptOptions != nil {
+			options = *e.acceptOptions
+		}
+		options.OriginPatterns = append(slices.Clone(options.OriginPatterns), e.allowedOrigins...)
+		e.acceptOptions = &options
+	}
 	go e.operate(ctx)
 	return e
 }
@@ -198,14 +284,35 @@ func (e *Engine) self(ctx context.Context, sock *Socket, msg Event) {
 }
 
 func (e *Engine) handleEmittedEvent(ctx context.Context, s *Socket, msg Event) {
-	if err := e.handleSelf(ctx, msg.T, s, msg); err != nil {
+	render, err := e.handleSelf(ctx, msg.T, s, msg)
+	if err != nil {
 		slog.Error("server event error", "err", err)
+		e.reportPanic(s, msg, err)
 	}
-	render, err := RenderSocket(ctx, e, s)
-	if err != nil {
+	if render {
+		e.renderEmitted(ctx, s, msg)
+	}
+}
+
+// renderEmitted renders a socket after a server side event.
+func (e *Engine) renderEmitted(ctx context.Context, s *Socket, msg Event) {
+	if err := e.renderLater(ctx, s); err != nil {
 		slog.Error("socket render error", "err", err)
+		e.reportPanic(s, msg, err)
+	}
+}
+
+// reportPanic lets the client know about a panic in a server side event, as
+// there is no client event for the error to be reported against.
+func (e *Engine) reportPanic(s *Socket, msg Event, err error) {
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		return
+	}
+	s.Send(EventError, ErrorEvent{Source: Event{T: msg.T}, Err: err.Error()})
+	if e.closeOnPanic(err) {
+		s.disconnect(websocket.StatusInternalError, "handler panic")
 	}
-	s.UpdateRender(render)
 }
 
 // AddSocket add a socket to the engine.
@@ -246,65 +353,143 @@ func (e *Engine) DeleteSocket(sock *Socket) {
 	defer close(op.resp)
 	e.deleteSocketC <- op
 	<-op.resp
-	if err := e.Handler.UnmountHandler(sock); err != nil {
+	// The socket is going regardless of the policy, a panic is only reported.
+	if err := e.callUnmount(context.Background(), sock); err != nil {
 		slog.Error("socket unmount error", "err", err)
 	}
-	e.socketStateStore.Delete(sock.ID())
+	sock.deleteState()
+	sock.close()
 }
 
+// detachSocket called when a sockets websocket disconnects. Depending on the
+// DisconnectGracePeriod the socket is either removed or kept for a time so that
+// it can be resumed, unless it isn't resumable.
+func (e *Engine) detachSocket(sock *Socket, c *websocket.Conn, resumable bool, reason error) {
+	grace := e.DisconnectGracePeriod
+	if !resumable {
+		grace = 0
+	}
+	sock.detachWS(c, grace, reason, func() {
+		e.DeleteSocket(sock)
+	})
+}
+
+// replyKey context key for the reply to an event.
+type replyKey struct{}
+
 // CallEvent route an event to the correct handler.
 func (e *Engine) CallEvent(ctx context.Context, t string, sock *Socket, msg Event) error {
-	handler, err := e.Handler.getEvent(t)
+	_, _, err := e.callEvent(ctx, t, sock, msg)
+	return err
+}
+
+// callEvent route an event to the correct handler, returning the handlers
+// reply if it has one, and whether the socket needs rendering.
+func (e *Engine) callEvent(ctx context.Context, t string, sock *Socket, msg Event) (reply any, render bool, err error) {
+	defer e.recoverPanic(ctx, sock, &err)
+
+	h := e.socketHandler(sock)
+	handler, err := h.getEvent(t)
 	if err != nil {
-		return err
+		return nil, true, err
 	}
 
 	params, err := msg.Params()
 	if err != nil {
-		return fmt.Errorf("received message and could not extract params: %w", err)
+		return nil, true, fmt.Errorf("received message and could not extract params: %w", err)
 	}
 
-	data, err := handler(ctx, sock, params)
+	ctx = context.WithValue(ctx, replyKey{}, &reply)
+	inv := &Invocation{Kind: InvokeEvent, Event: t, Socket: sock, Params: params}
+	data, err := h.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) (any, error) {
+		return handler(ctx, inv.Socket, inv.Params)
+	})
+	// The result of a superseded event is stale.
+	if cause := context.Cause(ctx); errors.Is(cause, ErrEventSuperseded) {
+		return nil, false, cause
+	}
 	if err != nil {
-		return err
+		return nil, true, err
 	}
 	sock.Assign(data)
 
-	return nil
+	return reply, data != NoRender, nil
 }
 
-// handleSelf route an event to the correct handler.
-func (e *Engine) handleSelf(ctx context.Context, t string, sock *Socket, msg Event) error {
-	handler, err := e.Handler.getSelf(t)
+// handleSelf route an event to the correct handler, returning whether the
+// socket needs rendering.
+func (e *Engine) handleSelf(ctx context.Context, t string, sock *Socket, msg Event) (render bool, err error) {
+	defer e.recoverPanic(ctx, sock, &err)
+
+	h := e.socketHandler(sock)
+	handler, err := h.getSelf(t)
 	if err != nil {
-		return fmt.Errorf("no self event handler for %s: %w", t, ErrNoEventHandler)
+		return true, fmt.Errorf("no self event handler for %s: %w", t, ErrNoEventHandler)
 	}
 
-	data, err := handler(ctx, sock, msg.SelfData)
+	inv := &Invocation{Kind: InvokeSelf, Event: t, Socket: sock, Data: msg.SelfData}
+	data, err := h.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) (any, error) {
+		return handler(ctx, inv.Socket, inv.Data)
+	})
 	if err != nil {
-		return fmt.Errorf("handler self event handler error [%s]: %w", t, err)
+		return true, fmt.Errorf("handler self event handler error [%s]: %w", t, err)
 	}
 	sock.Assign(data)
 
-	return nil
+	return data != NoRender, nil
 }
 
 // CallParams on params change run the handler.
 func (e *Engine) CallParams(ctx context.Context, sock *Socket, msg Event) error {
+	_, err := e.callParams(ctx, sock, msg)
+	return err
+}
+
+// callParams run the params handlers, returning whether the socket needs
+// rendering.
+func (e *Engine) callParams(ctx context.Context, sock *Socket, msg Event) (bool, error) {
 	params, err := msg.Params()
 	if err != nil {
-		return fmt.Errorf("received params message and could not extract params: %w", err)
+		return true, fmt.Errorf("received params message and could not extract params: %w", err)
+	}
+	// Path params can't be overridden by the client.
+	if params == nil {
+		params = Params{}
+	}
+	maps.Copy(params, sock.PathParams())
+
+	render, err := e.callParamsHandlers(ctx, sock, params)
+	if err != nil {
+		return true, fmt.Errorf("handler params handler error: %w", err)
 	}
 
-	for _, ph := range e.Handler.paramsHandlers {
-		data, err := ph(ctx, sock, params)
+	return render, nil
+}
+
+// callParamsHandlers run all of the params handlers, returning whether the
+// socket needs rendering, which it does unless every handler returned
+// NoRender.
+func (e *Engine) callParamsHandlers(ctx context.Context, sock *Socket, params Params) (render bool, err error) {
+	defer e.recoverPanic(ctx, sock, &err)
+
+	h := e.socketHandler(sock)
+	if len(h.paramsHandlers) == 0 {
+		return true, nil
+	}
+	for _, ph := range h.paramsHandlers {
+		inv := &Invocation{Kind: InvokeParams, Event: EventParams, Socket: sock, Params: params}
+		data, err := h.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) (any, error) {
+			return ph(ctx, inv.Socket, inv.Params)
+		})
 		if err != nil {
-			return fmt.Errorf("handler params handler error: %w", err)
+			return true, err
 		}
 		sock.Assign(data)
+		if data != NoRender {
+			render = true
+		}
 	}
-
-	return nil
+	return render, nil
 }
 
 // hasSocket check a socket is there error if it isn't connected or
@@ -350,13 +535,28 @@ func (e *Engine) post(ctx context.Context, w http.ResponseWriter, r *http.Reques
 	// Get socket.
 	sock, err := NewSocketFromRequest(ctx, e, r)
 	if err != nil {
-		e.Handler.ErrorHandler(ctx, err)
+		if errors.Is(err, ErrInvalidSocketID) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		e.requestHandler(ctx).ErrorHandler(ctx, err)
+		return
+	}
+	if err := e.hasSocket(sock); err != nil {
+		defer sock.close()
+	}
+	if err := e.checkRequest(r, sock.ID()); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err := e.authenticateSocket(ctx, r, sock); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
 	r.Body = http.MaxBytesReader(w, r.Body, e.MaxUploadSize)
 	if err := r.ParseMultipartForm(e.MaxUploadSize); err != nil {
-		e.Handler.ErrorHandler(ctx, fmt.Errorf("could not parse form for uploads: %w", err))
+		e.requestHandler(ctx).ErrorHandler(ctx, fmt.Errorf("could not parse form for uploads: %w", err))
 		return
 	}
 
@@ -364,7 +564,7 @@ func (e *Engine) post(ctx context.Context, w http.ResponseWriter, r *http.Reques
 	if e.UploadStagingLocation == "" {
 		uploadDir, err = os.MkdirTemp("", string(sock.ID()))
 		if err != nil {
-			e.Handler.ErrorHandler(ctx, fmt.Errorf("%s upload dir creation failed: %w", sock.ID(), err))
+			e.requestHandler(ctx).ErrorHandler(ctx, fmt.Errorf("%s upload dir creation failed: %w", sock.ID(), err))
 			return
 		}
 	}
@@ -375,12 +575,10 @@ func (e *Engine) post(ctx context.Context, w http.ResponseWriter, r *http.Reques
 			sock.AssignUpload(config.Name, u)
 			handleFileUpload(e, sock, config, u, uploadDir, fileHeader)
 
-			render, err := RenderSocket(ctx, e, sock)
-			if err != nil {
-				e.Handler.ErrorHandler(ctx, err)
+			if err := e.renderSocket(ctx, sock); err != nil {
+				e.requestHandler(ctx).ErrorHandler(ctx, err)
 				return
 			}
-			sock.UpdateRender(render)
 		}
 	}
 }
@@ -448,38 +646,51 @@ func handleFileUpload(h *Engine, sock *Socket, config *UploadConfig, u *Upload,
 
 // get renderer.
 func (e *Engine) get(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	principal, err := e.authenticate(ctx, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
 	// Get socket.
 	sock := NewSocket(ctx, e, "")
+	defer sock.close()
+	sock.setPrincipal(principal)
+	sock.setPathParams(pathParamsFromRequest(r))
+	sock.mergeFlash(e.flashFromRequest(w, r))
 
 	// Write ID to cookie.
 	sock.WriteFlashCookie(w)
 
 	// Run mount, this generates the state for the page we are on.
-	data, err := e.Handler.MountHandler(ctx, sock)
+	data, err := e.callMount(ctx, sock)
 	if err != nil {
-		e.Handler.ErrorHandler(ctx, err)
+		e.requestHandler(ctx).ErrorHandler(ctx, err)
 		return
 	}
 	sock.Assign(data)
 
 	// Handle any query parameters that are on the page.
-	for _, ph := range e.Handler.paramsHandlers {
-		data, err := ph(ctx, sock, NewParamsFromRequest(r))
-		if err != nil {
-			e.Handler.ErrorHandler(ctx, err)
-			return
-		}
-		sock.Assign(data)
+	if _, err := e.callParamsHandlers(ctx, sock, e.paramsFromRequest(r)); err != nil {
+		e.requestHandler(ctx).ErrorHandler(ctx, err)
+		return
 	}
 
 	// Render the HTML to display the page.
 	render, err := RenderSocket(ctx, e, sock)
 	if err != nil {
-		e.Handler.ErrorHandler(ctx, err)
+		e.requestHandler(ctx).ErrorHandler(ctx, err)
 		return
 	}
 	sock.UpdateRender(render)
 
+	setBodyAttr(render, LiveRendered, e.encodeSocketID(sock.ID()))
+	if e.csrfKey != nil {
+		setBodyAttr(render, liveCSRF, e.csrfToken(sock.ID()))
+	}
+	if e.socketCookie.Name != cookieSocketID {
+		setBodyAttr(render, liveSocketCookie, e.socketCookie.Name)
+	}
 	var rendered bytes.Buffer
 	html.Render(&rendered, render)
 
@@ -489,6 +700,33 @@ func (e *Engine) get(ctx context.Context, w http.ResponseWriter, r *http.Request
 
 // serveWS serve a websocket request to the handler.
 func (e *Engine) serveWS(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	// Check the socket ID before upgrading, so that the client gets
+	// a meaningful response.
+	sockID, err := e.socketIDFromReq(r)
+	if err == nil {
+		err = e.checkRequest(r, sockID)
+	}
+	if err != nil {
+		slog.Warn("ws rejected", "err", err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	principal, err := e.authenticate(ctx, r)
+	if err != nil {
+		slog.Warn("ws rejected", "err", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	key, err := e.admit(r, principal)
+	if err != nil {
+		slog.Warn("ws rejected", "err", err)
+		http.Error(w, err.Error(), admissionStatus(err))
+		return
+	}
+	defer e.release(key)
+
 	if strings.Contains(r.UserAgent(), "Safari") {
 		if e.acceptOptions == nil {
 			e.acceptOptions = &websocket.AcceptOptions{}
@@ -498,14 +736,22 @@ func (e *Engine) serveWS(ctx context.Context, w http.ResponseWriter, r *http.Req
 
 	c, err := websocket.Accept(w, r, e.acceptOptions)
 	if err != nil {
-		e.Handler.ErrorHandler(ctx, err)
+		e.requestHandler(ctx).ErrorHandler(ctx, err)
 		return
 	}
 	defer c.Close(websocket.StatusInternalError, "")
 	c.SetReadLimit(e.MaxMessageSize)
-	writeTimeout(ctx, time.Second*5, c, Event{T: EventConnect})
+	// Send the client a freshly signed ID to use when it reconnects.
+	connect := Event{T: EventConnect}
+	if e.socketIDSigner != nil {
+		if connect.Data, err = json.Marshal(e.encodeSocketID(sockID)); err != nil {
+			e.requestHandler(ctx).ErrorHandler(ctx, err)
+			return
+		}
+	}
+	writeTimeout(ctx, e.writeTimeout(), c, connect)
 	{
-		err := e._serveWS(ctx, r, c)
+		err := e._serveWS(ctx, r, c, principal)
 		if errors.Is(err, context.Canceled) {
 			return
 		}
@@ -524,105 +770,289 @@ func (e *Engine) serveWS(ctx context.Context, w http.ResponseWriter, r *http.Req
 }
 
 // _serveWS implement the logic for a web socket connection.
-func (e *Engine) _serveWS(ctx context.Context, r *http.Request, c *websocket.Conn) error {
+func (e *Engine) _serveWS(ctx context.Context, r *http.Request, c *websocket.Conn, principal any) (err error) {
 	// Get the sessions socket and register it with the server.
 	sock, err := NewSocketFromRequest(ctx, e, r)
 	if err != nil {
 		return fmt.Errorf("failed precondition: %w", err)
 	}
-	sock.assignWS(c)
+	// An existing socket, held by this engine or restored from the state
+	// store, can only be attached to by its own principal.
+	held := e.hasSocket(sock) == nil
+	if held || sock.restored {
+		if !e.samePrincipal(principal, sock.Principal()) {
+			if !held {
+				sock.close()
+			}
+			return fmt.Errorf("failed precondition: %w: principal does not match socket", ErrUnauthorized)
+		}
+	} else {
+		sock.setPrincipal(principal)
+	}
+	connectParams, err := connectParamsFromRequest(r)
+	if err != nil {
+		return fmt.Errorf("failed precondition: %w", err)
+	}
+	sock.setConnectParams(connectParams)
+	sock.setPathParams(pathParamsFromRequest(r))
+	resumed, err := sock.assignWS(c)
+	if err != nil {
+		return fmt.Errorf("failed precondition: %w", err)
+	}
 	e.AddSocket(sock)
-	defer e.DeleteSocket(sock)
+	// A socket whose principal has been revoked can't be resumed.
+	revoked := false
+	defer func() {
+		e.detachSocket(sock, c, !revoked, err)
+	}()
+
+	// Periodically check that the principal is still valid.
+	var revalidate <-chan time.Time
+	if e.authenticator != nil && e.revalidateInterval > 0 {
+		ticker := time.NewTicker(e.revalidateInterval)
+		defer ticker.Stop()
+		revalidate = ticker.C
+	}
+
+	// Keep the clients signed ID fresh, so that it can resume the socket
+	// however long it has been connected.
+	var refreshID <-chan time.Time
+	if e.socketIDSigner != nil {
+		ticker := time.NewTicker(e.socketIDSigner.ttl / 2)
+		defer ticker.Stop()
+		refreshID = ticker.C
+	}
+
+	// Limit events from all of the sockets from this clients IP.
+	var ipLimit *rate.Limiter
+	if e.ipLimiters != nil {
+		ip := e.eventLimits.PerIPKey(r, principal)
+		ipLimit = e.ipLimiters.acquire(ip)
+		defer e.ipLimiters.release(ip)
+	}
+
+	// Cancel the reading and handling of events once the connection ends.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	// Internal errors.
 	internalErrors := make(chan error)
+	internalError := func(err error) {
+		select {
+		case internalErrors <- err:
+		case <-ctx.Done():
+		}
+	}
 
 	// Event errors.
 	eventErrors := make(chan ErrorEvent)
+	eventError := func(ee ErrorEvent) {
+		select {
+		case eventErrors <- ee:
+		case <-ctx.Done():
+		}
+	}
+
+	// Events handled latest wins, tracked per connection.
+	latest := &latestEvents{}
+
+	// Events read from the websocket, and those waiting to be handled.
+	received := make(chan Event)
+	inbound := make(chan Event, maxMessageBufferSize)
 
-	// Handle events coming from the websocket connection.
+	// Read events from the websocket connection. Replies to calls are
+	// resolved as they arrive, so that a handler waiting on a call isn't
+	// blocked behind itself.
 	go func() {
+		defer close(received)
 		for {
 			t, d, err := c.Read(ctx)
 			if err != nil {
-				internalErrors <- err
-				break
+				internalError(err)
+				return
 			}
 			switch t {
 			case websocket.MessageText:
 				var m Event
 				if err := json.Unmarshal(d, &m); err != nil {
-					internalErrors <- err
+					internalError(err)
+					return
+				}
+				if m.T == EventReply {
+					sock.resolveCall(m)
 					break
 				}
-				switch m.T {
-				case EventParams:
-					if err := e.CallParams(ctx, sock, m); err != nil {
-						switch {
-						case errors.Is(err, ErrNoEventHandler):
-							slog.Error("event params error", "event", m, "err", err)
-						default:
-							eventErrors <- ErrorEvent{Source: m, Err: err.Error()}
-						}
+				if !e.allowEvent(sock, ipLimit) {
+					if e.eventLimits.Policy == LimitDisconnect {
+						slog.Warn("socket over event limit", "socket", sock.ID())
+						c.Close(websocket.StatusPolicyViolation, "too many events")
+						break
 					}
-				default:
-					if err := e.CallEvent(ctx, m.T, sock, m); err != nil {
-						switch {
-						case errors.Is(err, ErrNoEventHandler):
-							slog.Error("event default error", "event", m, "err", err)
-						default:
-							eventErrors <- ErrorEvent{Source: m, Err: err.Error()}
-						}
+					if err := sock.Send(EventAck, nil, WithID(m.ID)); errors.Is(err, ErrOutboundFull) {
+						slog.Warn("socket ack not sent", "socket", sock.ID(), "err", err)
+					} else if err != nil {
+						internalError(fmt.Errorf("socket send error: %w", err))
 					}
+					break
 				}
-				render, err := RenderSocket(ctx, e, sock)
-				if err != nil {
-					internalErrors <- fmt.Errorf("socket handle error: %w", err)
-				} else {
-					sock.UpdateRender(render)
+				// A newer event cancels the one being handled.
+				if e.socketHandler(sock).cancelsPrevious(m.T) {
+					latest.queue(m.T)
 				}
-				if err := sock.Send(EventAck, nil, WithID(m.ID)); err != nil {
-					internalErrors <- fmt.Errorf("socket send error: %w", err)
+				select {
+				case received <- m:
+				case <-ctx.Done():
+					return
 				}
 			case websocket.MessageBinary:
 				slog.Warn("binary messages unhandled")
 			}
 		}
-		close(internalErrors)
-		close(eventErrors)
 	}()
 
-	// Run mount again now that eh socket is connected, passing true indicating
-	// a connection has been made.
-	data, err := e.Handler.MountHandler(ctx, sock)
-	if err != nil {
-		return fmt.Errorf("socket mount error: %w", err)
-	}
-	sock.Assign(data)
+	// Move read events on to be handled. Reading stops once the handler
+	// falls behind, unless it is waiting on a call, as the reply may be
+	// behind the events already sent. A client which keeps sending events
+	// without replying to the call is closed.
+	go func() {
+		defer close(inbound)
+		var backlog []Event
+		for {
+			var out chan Event
+			var next Event
+			if len(backlog) > 0 {
+				out, next = inbound, backlog[0]
+			}
+			in := received
+			if len(backlog) >= maxMessageBufferSize && !sock.calling() {
+				in = nil
+			}
+			select {
+			case m, ok := <-in:
+				if !ok {
+					return
+				}
+				if len(backlog) >= maxMessageBufferSize && sock.calling() {
+					slog.Warn("socket over inbound limit while calling", "socket", sock.ID())
+					c.Close(websocket.StatusPolicyViolation, "too many events")
+					return
+				}
+				backlog = append(backlog, m)
+			case out <- next:
+				backlog = backlog[1:]
+			case <-sock.callStarted:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
-	// Run params again now that the socket is connected.
-	for _, ph := range e.Handler.paramsHandlers {
-		data, err := ph(ctx, sock, NewParamsFromRequest(r))
-		if err != nil {
-			return fmt.Errorf("socket params error: %w", err)
+	// Handle events coming from the websocket connection, once the socket is
+	// mounted. The socket is mounted while messages are being sent, so that
+	// mount can wait on a Call.
+	go func() {
+		// A resumed socket already has its state, and any messages queued
+		// while it was detached will be flushed below. It is rendered in
+		// case a patch was dropped while it was detached. A socket restored
+		// from the state store, even one which was connected to another
+		// engine, is mounted as only its state has moved.
+		switch {
+		case resumed:
+			if err := e.renderSocket(ctx, sock); err != nil && !errors.Is(err, ErrOutboundFull) {
+				internalError(fmt.Errorf("socket render error: %w", err))
+				return
+			}
+		default:
+			if err := e.mountWS(ctx, r, sock); err != nil {
+				internalError(err)
+				return
+			}
 		}
-		sock.Assign(data)
-	}
 
-	// Run render now that we are connected for the first time and we have just
-	// mounted again. This will generate and send any patches if there have
-	// been changes.
-	render, err := RenderSocket(ctx, e, sock)
-	if err != nil {
-		return fmt.Errorf("socket render error: %w", err)
-	}
-	sock.UpdateRender(render)
+		for m := range inbound {
+			var reply any
+			var err error
+			render := true
+			switch m.T {
+			case EventNavigate:
+				if err := e.navigate(ctx, r, sock, m); err != nil {
+					eventError(ErrorEvent{Source: m, Err: err.Error()})
+					if e.closeOnPanic(err) {
+						internalError(err)
+					}
+				}
+			case EventParams:
+				if render, err = e.callParams(ctx, sock, m); err != nil {
+					switch {
+					case errors.Is(err, ErrNoEventHandler):
+						slog.Error("event params error", "event", m, "err", err)
+					default:
+						eventError(ErrorEvent{Source: m, Err: err.Error()})
+						if e.closeOnPanic(err) {
+							internalError(err)
+						}
+					}
+				}
+			default:
+				// A newer event is waiting, so this one is skipped.
+				ctx, ok := latest.start(ctx, m.T)
+				if !ok {
+					render = false
+					break
+				}
+				reply, render, err = e.callEvent(ctx, m.T, sock, m)
+				latest.end(m.T)
+				if err != nil {
+					switch {
+					case errors.Is(err, ErrEventSuperseded):
+						// A newer event replaces this one.
+					case errors.Is(err, ErrNoEventHandler):
+						slog.Error("event default error", "event", m, "err", err)
+						threshold := e.eventLimits.UnknownEventThreshold
+						if threshold > 0 && sock.unknownEvent() > threshold {
+							slog.Warn("socket over unknown event threshold", "socket", sock.ID())
+							c.Close(websocket.StatusPolicyViolation, "too many unknown events")
+						}
+					default:
+						eventError(ErrorEvent{Source: m, Err: err.Error()})
+						if e.closeOnPanic(err) {
+							internalError(err)
+						}
+					}
+				}
+			}
+			if render {
+				err = e.renderSocket(ctx, sock)
+				var pe *PanicError
+				if err != nil && errors.As(err, &pe) && !e.closeOnPanic(err) {
+					eventError(ErrorEvent{Source: m, Err: err.Error()})
+				} else if errors.Is(err, ErrOutboundFull) {
+					slog.Warn("socket render not sent", "socket", sock.ID(), "err", err)
+				} else if err != nil {
+					internalError(fmt.Errorf("socket handle error: %w", err))
+				}
+			}
+			// The outbound policy has already dealt with a full queue.
+			if err := sock.Send(EventAck, reply, WithID(m.ID)); errors.Is(err, ErrOutboundFull) {
+				slog.Warn("socket ack not sent", "socket", sock.ID(), "err", err)
+			} else if err != nil {
+				internalError(fmt.Errorf("socket send error: %w", err))
+			}
+		}
+	}()
 
-	// Send events to the websocket connection.
+	// Send events to the websocket connection, once the connection this
+	// one replaced has stopped.
+	select {
+	case sock.writer <- struct{}{}:
+		defer func() { <-sock.writer }()
+	case <-ctx.Done():
+		return nil
+	}
 	for {
 		select {
 		case msg := <-sock.msgs:
-			if err := writeTimeout(ctx, time.Second*5, c, msg); err != nil {
+			if err := writeTimeout(ctx, e.writeTimeout(), c, msg); err != nil {
 				return fmt.Errorf("writing to socket error: %w", err)
 			}
 		case ee := <-eventErrors:
@@ -630,7 +1060,7 @@ func (e *Engine) _serveWS(ctx context.Context, r *http.Request, c *websocket.Con
 			if err != nil {
 				return fmt.Errorf("writing to socket error: %w", err)
 			}
-			if err := writeTimeout(ctx, time.Second*5, c, Event{T: EventError, Data: d}); err != nil {
+			if err := writeTimeout(ctx, e.writeTimeout(), c, Event{T: EventError, Data: d}); err != nil {
 				return fmt.Errorf("writing to socket error: %w", err)
 			}
 		case err := <-internalErrors:
@@ -639,14 +1069,61 @@ func (e *Engine) _serveWS(ctx context.Context, r *http.Request, c *websocket.Con
 				if err != nil {
 					return fmt.Errorf("writing to socket error: %w", err)
 				}
-				if err := writeTimeout(ctx, time.Second*5, c, Event{T: EventError, Data: d}); err != nil {
+				if err := writeTimeout(ctx, e.writeTimeout(), c, Event{T: EventError, Data: d}); err != nil {
 					return fmt.Errorf("writing to socket error: %w", err)
 				}
 				// Something catastrophic has happened.
 				return fmt.Errorf("internal error: %w", err)
 			}
+		case <-refreshID:
+			d, err := json.Marshal(e.encodeSocketID(sock.ID()))
+			if err != nil {
+				return fmt.Errorf("writing to socket error: %w", err)
+			}
+			if err := writeTimeout(ctx, e.writeTimeout(), c, Event{T: EventSocketID, Data: d}); err != nil {
+				return fmt.Errorf("writing to socket error: %w", err)
+			}
+		case <-revalidate:
+			if err := e.revalidate(ctx, sock); err != nil {
+				revoked = true
+				if e.revokedRedirect != nil {
+					d, err := json.Marshal(e.revokedRedirect.String())
+					if err != nil {
+						return fmt.Errorf("writing to socket error: %w", err)
+					}
+					if err := writeTimeout(ctx, e.writeTimeout(), c, Event{T: EventRedirect, Data: d}); err != nil {
+						return fmt.Errorf("writing to socket error: %w", err)
+					}
+				}
+				c.Close(websocket.StatusPolicyViolation, "session revoked")
+				return fmt.Errorf("socket revoked: %w", err)
+			}
 		case <-ctx.Done():
 			return nil
 		}
 	}
 }
+
+// mountWS mount a socket now that its websocket has connected for the first time.
+func (e *Engine) mountWS(ctx context.Context, r *http.Request, sock *Socket) error {
+	// Run mount again now that eh socket is connected, passing true indicating
+	// a connection has been made.
+	data, err := e.callMount(ctx, sock)
+	if err != nil {
+		return fmt.Errorf("socket mount error: %w", err)
+	}
+	sock.Assign(data)
+
+	// Run params again now that the socket is connected.
+	if _, err := e.callParamsHandlers(ctx, sock, e.paramsFromRequest(r)); err != nil {
+		return fmt.Errorf("socket params error: %w", err)
+	}
+
+	// Run render now that we are connected for the first time and we have just
+	// mounted again. This will generate and send any patches if there have
+	// been changes.
+	if err := e.renderSocket(ctx, sock); err != nil {
+		return fmt.Errorf("socket render error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,852 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+func testHandler() *Handler {
+	return testView(func(rc *RenderContext) string { return "test" })
+}
+
+// testView a handler rendering the body, for tests to add their own handlers
+// to.
+func testView(body func(rc *RenderContext) string) *Handler {
+	h := NewHandler()
+	h.RenderHandler = func(ctx context.Context, rc *RenderContext) (io.Reader, error) {
+		return strings.NewReader(`<html><head></head><body>` + body(rc) + `</body></html>`), nil
+	}
+	return h
+}
+
+// testCounter counts the mounts, unmounts and renders of a handler.
+type testCounter struct {
+	mounts, unmounts, renders atomic.Int32
+}
+
+// count wraps the handlers mount, unmount and render handlers.
+func (c *testCounter) count(h *Handler) *Handler {
+	mount, unmount, render := h.MountHandler, h.UnmountHandler, h.RenderHandler
+	h.MountHandler = func(ctx context.Context, s *Socket) (any, error) {
+		c.mounts.Add(1)
+		return mount(ctx, s)
+	}
+	h.UnmountHandler = func(s *Socket) error {
+		c.unmounts.Add(1)
+		return unmount(s)
+	}
+	h.RenderHandler = func(ctx context.Context, rc *RenderContext) (io.Reader, error) {
+		c.renders.Add(1)
+		return render(ctx, rc)
+	}
+	return h
+}
+
+func testServer(t *testing.T, h *Handler, configs ...EngineConfig) (*Engine, string) {
+	t.Helper()
+	e := NewHttpHandler(t.Context(), h, configs...)
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+	return e, "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// testConnect serves the handler, dials it and waits for the socket to be
+// added to the engine.
+func testConnect(t *testing.T, h *Handler, id SocketID, configs ...EngineConfig) (*Engine, *Socket, *websocket.Conn) {
+	t.Helper()
+	e, wsURL := testServer(t, h, configs...)
+	c := testDial(t, wsURL, id)
+	t.Cleanup(func() { c.Close(websocket.StatusNormalClosure, "") })
+	var sock *Socket
+	eventually(t, func() bool {
+		var err error
+		sock, err = e.GetSocket(id)
+		return err == nil
+	})
+	return e, sock, c
+}
+
+func testDial(t *testing.T, url string, id SocketID) *websocket.Conn {
+	t.Helper()
+	c, _, err := websocket.Dial(context.Background(), url, &websocket.DialOptions{
+		HTTPHeader: http.Header{"Cookie": []string{cookieSocketID + "=" + string(id)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev := testRead(t, c); ev.T != EventConnect {
+		t.Fatalf("expected connect event, got %s", ev.T)
+	}
+	return c
+}
+
+func testRead(t *testing.T, c *websocket.Conn) Event {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, d, err := c.Read(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ev Event
+	if err := json.Unmarshal(d, &ev); err != nil {
+		t.Fatal(err)
+	}
+	return ev
+}
+
+func eventually(t *testing.T, check func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !check() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDisconnectGracePeriodResume(t *testing.T) {
+	var mounts, unmounts atomic.Int32
+	h := testHandler()
+	h.MountHandler = func(ctx context.Context, s *Socket) (any, error) {
+		if s.Connected() {
+			mounts.Add(1)
+		}
+		return nil, nil
+	}
+	h.UnmountHandler = func(s *Socket) error {
+		unmounts.Add(1)
+		return nil
+	}
+	e, url := testServer(t, h, WithDisconnectGracePeriod(200*time.Millisecond))
+
+	c := testDial(t, url, "grace")
+	eventually(t, func() bool { return mounts.Load() == 1 })
+	c.Close(websocket.StatusNormalClosure, "")
+
+	var sock *Socket
+	eventually(t, func() bool {
+		s, err := e.GetSocket("grace")
+		if err != nil || !s.Detached() {
+			return false
+		}
+		sock = s
+		return true
+	})
+	if err := sock.Send("queued", "while detached"); err != nil {
+		t.Fatal(err)
+	}
+
+	c = testDial(t, url, "grace")
+	if ev := testRead(t, c); ev.T != "queued" {
+		t.Errorf("expected queued event after resume, got %s", ev.T)
+	}
+	if mounts.Load() != 1 {
+		t.Errorf("expected resumed socket not to mount again, got %d mounts", mounts.Load())
+	}
+	if unmounts.Load() != 0 {
+		t.Errorf("expected resumed socket not to unmount, got %d unmounts", unmounts.Load())
+	}
+	c.Close(websocket.StatusNormalClosure, "")
+
+	eventually(t, func() bool { return unmounts.Load() == 1 })
+	if _, err := e.GetSocket("grace"); err != ErrNoSocket {
+		t.Errorf("expected socket to be removed after grace period, got %v", err)
+	}
+}
+
+func TestDisconnectGracePeriodFullQueue(t *testing.T) {
+	h := testHandler()
+	e, url := testServer(t, h, WithDisconnectGracePeriod(time.Second))
+
+	c := testDial(t, url, "grace-full")
+	c.Close(websocket.StatusNormalClosure, "")
+	var sock *Socket
+	eventually(t, func() bool {
+		s, err := e.GetSocket("grace-full")
+		if err != nil || !s.Detached() {
+			return false
+		}
+		sock = s
+		return true
+	})
+
+	// More messages than the queue holds don't expire the socket, the
+	// stale ones are dropped.
+	for i := range 3 * maxMessageBufferSize {
+		sock.Send("price", i)
+	}
+	if _, err := e.GetSocket("grace-full"); err != nil {
+		t.Fatalf("expected socket to wait to be resumed, got %v", err)
+	}
+
+	c = testDial(t, url, "grace-full")
+	defer c.Close(websocket.StatusNormalClosure, "")
+	// The queue holds the latest prices.
+	if ev := testRead(t, c); ev.T != "price" || string(ev.Data) != fmt.Sprint(2*maxMessageBufferSize) {
+		t.Errorf("expected the oldest prices to be dropped, got %s %s", ev.T, ev.Data)
+	}
+}
+
+func TestDisconnectNoGracePeriod(t *testing.T) {
+	var unmounts atomic.Int32
+	h := testHandler()
+	h.UnmountHandler = func(s *Socket) error {
+		unmounts.Add(1)
+		return nil
+	}
+	e, url := testServer(t, h)
+
+	c := testDial(t, url, "nograce")
+	eventually(t, func() bool {
+		_, err := e.GetSocket("nograce")
+		return err == nil
+	})
+	c.Close(websocket.StatusNormalClosure, "")
+
+	eventually(t, func() bool { return unmounts.Load() == 1 })
+	if _, err := e.GetSocket("nograce"); err != ErrNoSocket {
+		t.Errorf("expected socket to be removed, got %v", err)
+	}
+}
+
+func TestSocketRehydrateFromStore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := NewMemorySocketStateStore(ctx)
+
+	h := testHandler()
+	h.MountHandler = func(ctx context.Context, s *Socket) (any, error) {
+		return "assigned", nil
+	}
+
+	// Render the page on one engine.
+	first := NewHttpHandler(ctx, h, WithSocketStateStore(store))
+	req := httptest.NewRequest("GET", "/", nil)
+	rr := httptest.NewRecorder()
+	first.get(httpContext(rr, req), rr, req)
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected socket cookie, got %v", cookies)
+	}
+
+	// Pick the socket up on another engine sharing the store.
+	second := NewHttpHandler(ctx, h, WithSocketStateStore(store))
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookies[0])
+	sock, err := NewSocketFromRequest(ctx, second, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sock.close()
+
+	if sock.Assigns() != "assigned" {
+		t.Errorf("expected assigns to be rehydrated, got %v", sock.Assigns())
+	}
+	if sock.LatestRender() == nil {
+		t.Fatal("expected render to be rehydrated")
+	}
+	encoded, err := encodeRender(sock.LatestRender())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The page sent to the client also has the socket ID rendered in.
+	expected := strings.Replace(rr.Body.String(), `live-rendered="`+cookies[0].Value+`"`, `live-rendered=""`, 1)
+	if string(encoded) != expected {
+		t.Errorf("rehydrated render does not match, got %s want %s", encoded, expected)
+	}
+}
+
+func TestResumeOnAnotherEngine(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := NewMemorySocketStateStore(ctx)
+
+	var mounts, unmounts atomic.Int32
+	h := testHandler()
+	h.MountHandler = func(ctx context.Context, s *Socket) (any, error) {
+		if s.Connected() {
+			mounts.Add(1)
+		}
+		if n, ok := s.Assigns().(int); ok {
+			return n, nil
+		}
+		return 0, nil
+	}
+	h.UnmountHandler = func(s *Socket) error {
+		unmounts.Add(1)
+		return nil
+	}
+	h.HandleEvent("inc", func(ctx context.Context, s *Socket, p Params) (any, error) {
+		return s.Assigns().(int) + 1, nil
+	})
+	ack := func(c *websocket.Conn, id int) {
+		t.Helper()
+		for {
+			if ev := testRead(t, c); ev.T == EventAck && ev.ID == id {
+				return
+			}
+		}
+	}
+
+	first, firstURL := testServer(t, h, WithSocketStateStore(store), WithDisconnectGracePeriod(50*time.Millisecond))
+	second, secondURL := testServer(t, h, WithSocketStateStore(store))
+
+	c := testDial(t, firstURL, "roam")
+	testWrite(t, c, Event{T: "inc", ID: 1})
+	ack(c, 1)
+	c.Close(websocket.StatusNormalClosure, "")
+	eventually(t, func() bool {
+		s, err := first.GetSocket("roam")
+		return err == nil && s.Detached()
+	})
+
+	// The client reconnects to another engine while the first still
+	// holds the detached socket.
+	c = testDial(t, secondURL, "roam")
+	defer c.Close(websocket.StatusNormalClosure, "")
+	eventually(t, func() bool {
+		_, err := second.GetSocket("roam")
+		return err == nil
+	})
+	testWrite(t, c, Event{T: "inc", ID: 2})
+	ack(c, 2)
+	if mounts.Load() != 2 {
+		t.Errorf("expected socket to mount again on the engine it moved to, got %d mounts", mounts.Load())
+	}
+
+	// The first engine expiring its socket leaves the state alone.
+	eventually(t, func() bool { return unmounts.Load() == 1 })
+	sock, err := second.GetSocket("roam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sock.Assigns() != 2 {
+		t.Errorf("expected assigns to carry over, got %v", sock.Assigns())
+	}
+}
+
+func testWrite(t *testing.T, c *websocket.Conn, ev Event) {
+	t.Helper()
+	d, err := json.Marshal(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Write(context.Background(), websocket.MessageText, d); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	for _, policy := range []PanicPolicy{PanicContinue, PanicClose} {
+		var panics atomic.Int32
+		h := testHandler()
+		h.HandleEvent("boom", func(ctx context.Context, s *Socket, p Params) (any, error) {
+			panic("boom")
+		})
+		_, url := testServer(t, h, WithPanicPolicy(policy), WithPanicHandler(func(ctx context.Context, s *Socket, err *PanicError) {
+			if len(err.Stack) == 0 {
+				t.Error("expected stack trace")
+			}
+			panics.Add(1)
+		}))
+
+		c := testDial(t, url, SocketID(fmt.Sprintf("panic-%d", policy)))
+		testWrite(t, c, Event{T: "boom", ID: 1})
+		if ev := testRead(t, c); ev.T != EventError {
+			t.Fatalf("expected error event, got %s", ev.T)
+		}
+		if panics.Load() != 1 {
+			t.Errorf("expected panic handler to be called once, got %d", panics.Load())
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, _, err := c.Read(ctx)
+		cancel()
+		switch policy {
+		case PanicContinue:
+			if err != nil {
+				t.Errorf("expected socket to continue, got %s", err)
+			}
+		case PanicClose:
+			if err == nil {
+				// The ack may be written before the close.
+				_, _, err = c.Read(context.Background())
+			}
+			if err == nil {
+				t.Error("expected socket to close")
+			}
+		}
+		c.Close(websocket.StatusNormalClosure, "")
+	}
+}
+
+func TestPanicRecoveryMount(t *testing.T) {
+	h := testHandler()
+	h.MountHandler = func(ctx context.Context, s *Socket) (any, error) {
+		panic("boom")
+	}
+	var recovered *PanicError
+	e := NewHttpHandler(context.Background(), h, WithPanicHandler(func(ctx context.Context, s *Socket, err *PanicError) {
+		recovered = err
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rr := httptest.NewRecorder()
+	e.get(httpContext(rr, req), rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected internal server error, got %d", rr.Code)
+	}
+	if recovered == nil || recovered.Value != "boom" {
+		t.Errorf("expected panic to be recovered, got %v", recovered)
+	}
+}
+
+func TestPanicRecoveryUnmount(t *testing.T) {
+	h := testHandler()
+	h.UnmountHandler = func(s *Socket) error {
+		panic("boom")
+	}
+	recovered := make(chan *PanicError, 1)
+	e, url := testServer(t, h, WithPanicHandler(func(ctx context.Context, s *Socket, err *PanicError) {
+		recovered <- err
+	}))
+
+	c := testDial(t, url, "panic-unmount")
+	c.Close(websocket.StatusNormalClosure, "")
+	select {
+	case err := <-recovered:
+		if err.Value != "boom" {
+			t.Errorf("expected unmount panic, got %v", err.Value)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected unmount panic to be recovered")
+	}
+	eventually(t, func() bool {
+		_, err := e.GetSocket("panic-unmount")
+		return err != nil
+	})
+}
+
+func TestConnectParams(t *testing.T) {
+	var params atomic.Value
+	h := testHandler()
+	h.MountHandler = func(ctx context.Context, s *Socket) (any, error) {
+		if s.Connected() {
+			params.Store(s.ConnectParams())
+		}
+		return nil, nil
+	}
+	_, wsURL := testServer(t, h)
+
+	q := url.Values{connectParam: []string{`{"timezone":"Europe/London","viewport":{"width":800}}`}}
+	c := testDial(t, wsURL+"?"+q.Encode(), "connect")
+	defer c.Close(websocket.StatusNormalClosure, "")
+
+	eventually(t, func() bool { return params.Load() != nil })
+	p := params.Load().(Params)
+	if p.String("timezone") != "Europe/London" {
+		t.Errorf("expected timezone, got %v", p)
+	}
+	if viewport, ok := p["viewport"].(map[string]any); !ok || viewport["width"] != float64(800) {
+		t.Errorf("expected viewport, got %v", p)
+	}
+}
+
+func TestEventReply(t *testing.T) {
+	h := testHandler()
+	h.HandleEventReply("search", func(ctx context.Context, s *Socket, p Params) (any, any, error) {
+		return s.Assigns(), []string{p.String("q") + "-result"}, nil
+	})
+	_, wsURL := testServer(t, h)
+	c := testDial(t, wsURL, "reply")
+	defer c.Close(websocket.StatusNormalClosure, "")
+
+	testWrite(t, c, Event{T: "search", ID: 5, Data: json.RawMessage(`{"q":"london"}`)})
+	ev := testRead(t, c)
+	for ev.T != EventAck {
+		ev = testRead(t, c)
+	}
+	if ev.ID != 5 {
+		t.Fatalf("expected ack for event 5, got %d", ev.ID)
+	}
+	var reply []string
+	if err := json.Unmarshal(ev.Data, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if len(reply) != 1 || reply[0] != "london-result" {
+		t.Errorf("unexpected reply %v", reply)
+	}
+}
+
+func TestCancelPrevious(t *testing.T) {
+	var mu sync.Mutex
+	var handled []string
+	var causes []error
+	h := testHandler()
+	h.HandleEvent("search", func(ctx context.Context, s *Socket, p Params) (any, error) {
+		q := p.String("q")
+		mu.Lock()
+		handled = append(handled, q)
+		mu.Unlock()
+		if q == "l" {
+			<-ctx.Done()
+			mu.Lock()
+			causes = append(causes, context.Cause(ctx))
+			mu.Unlock()
+			// Give the last event time to arrive while the first is
+			// finishing.
+			time.Sleep(50 * time.Millisecond)
+		}
+		return q, nil
+	}, CancelPrevious())
+	var counter testCounter
+	e, wsURL := testServer(t, counter.count(h))
+	c := testDial(t, wsURL, "latest")
+	defer c.Close(websocket.StatusNormalClosure, "")
+	eventually(t, func() bool { return counter.renders.Load() > 0 })
+	mounted := counter.renders.Load()
+
+	testWrite(t, c, Event{T: "search", ID: 1, Data: json.RawMessage(`{"q":"l"}`)})
+	eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 1
+	})
+	testWrite(t, c, Event{T: "search", ID: 2, Data: json.RawMessage(`{"q":"lo"}`)})
+	testWrite(t, c, Event{T: "search", ID: 3, Data: json.RawMessage(`{"q":"lon"}`)})
+
+	acks := map[int]bool{}
+	for len(acks) < 3 {
+		if ev := testRead(t, c); ev.T == EventAck {
+			acks[ev.ID] = true
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if fmt.Sprint(handled) != "[l lon]" {
+		t.Errorf("expected waiting event to be skipped, handled %v", handled)
+	}
+	if len(causes) != 1 || !errors.Is(causes[0], ErrEventSuperseded) {
+		t.Errorf("expected first event to be superseded, got %v", causes)
+	}
+	sock, err := e.GetSocket("latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sock.Assigns() != "lon" {
+		t.Errorf("expected latest result to be assigned, got %v", sock.Assigns())
+	}
+	if n := counter.renders.Load() - mounted; n != 1 {
+		t.Errorf("expected only the latest event to render, got %d renders", n)
+	}
+}
+
+func TestNoRender(t *testing.T) {
+	var renders atomic.Int32
+	h := NewHandler()
+	h.MountHandler = func(ctx context.Context, s *Socket) (any, error) {
+		return "mounted", nil
+	}
+	h.RenderHandler = func(ctx context.Context, rc *RenderContext) (io.Reader, error) {
+		renders.Add(1)
+		return strings.NewReader(fmt.Sprintf(`<html><head></head><body>%v</body></html>`, rc.Assigns)), nil
+	}
+	h.HandleEvent("track", func(ctx context.Context, s *Socket, p Params) (any, error) {
+		return NoRender, nil
+	})
+	h.HandleEvent("change", func(ctx context.Context, s *Socket, p Params) (any, error) {
+		return "changed", nil
+	})
+	h.HandleParams(func(ctx context.Context, s *Socket, p Params) (any, error) {
+		if p.String("page") == "" {
+			return NoRender, nil
+		}
+		return p.String("page"), nil
+	})
+	h.HandleParams(func(ctx context.Context, s *Socket, p Params) (any, error) {
+		return NoRender, nil
+	})
+	e, wsURL := testServer(t, h)
+	c := testDial(t, wsURL, "norender")
+	defer c.Close(websocket.StatusNormalClosure, "")
+
+	ack := func(id int) {
+		t.Helper()
+		for {
+			ev := testRead(t, c)
+			if ev.T == EventAck && ev.ID == id {
+				return
+			}
+		}
+	}
+	testWrite(t, c, Event{T: "track", ID: 1})
+	ack(1)
+	before := renders.Load()
+	testWrite(t, c, Event{T: "track", ID: 2})
+	ack(2)
+	if renders.Load() != before {
+		t.Errorf("expected NoRender to skip render, got %d renders want %d", renders.Load(), before)
+	}
+	sock, err := e.GetSocket("norender")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sock.Assigns() != "mounted" {
+		t.Errorf("expected assigns to be unchanged, got %v", sock.Assigns())
+	}
+
+	testWrite(t, c, Event{T: "change", ID: 3})
+	ack(3)
+	if renders.Load() != before+1 {
+		t.Errorf("expected change to render, got %d renders want %d", renders.Load(), before+1)
+	}
+
+	// A params handler returning NoRender doesn't skip the render for an
+	// earlier one which made changes.
+	testWrite(t, c, Event{T: EventParams, ID: 4, Data: json.RawMessage(`{"page":"2"}`)})
+	ack(4)
+	if renders.Load() != before+2 {
+		t.Errorf("expected params change to render, got %d renders want %d", renders.Load(), before+2)
+	}
+}
+
+func TestRenderInterval(t *testing.T) {
+	var renders atomic.Int32
+	var rendered atomic.Value
+	h := NewHandler()
+	h.RenderHandler = func(ctx context.Context, rc *RenderContext) (io.Reader, error) {
+		renders.Add(1)
+		rendered.Store(fmt.Sprint(rc.Assigns))
+		return strings.NewReader(fmt.Sprintf(`<html><head></head><body>%v</body></html>`, rc.Assigns)), nil
+	}
+	h.HandleSelf("tick", func(ctx context.Context, s *Socket, d any) (any, error) {
+		return d, nil
+	})
+	// The interval is long enough that the test flushes the render.
+	e, wsURL := testServer(t, h, WithRenderInterval(time.Hour))
+	c := testDial(t, wsURL, "interval")
+	defer c.Close(websocket.StatusNormalClosure, "")
+
+	var sock *Socket
+	eventually(t, func() bool {
+		var err error
+		sock, err = e.GetSocket("interval")
+		return err == nil && renders.Load() > 0
+	})
+	before := renders.Load()
+	for i := range 10 {
+		if err := sock.Self(context.Background(), "tick", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := renders.Load() - before; n != 0 {
+		t.Fatalf("expected ticks to wait for the interval, got %d renders", n)
+	}
+	e.flushRender(sock)
+	if n := renders.Load() - before; n != 1 || rendered.Load() != "9" {
+		t.Errorf("expected ticks to be batched into one render, got %d renders of %v", n, rendered.Load())
+	}
+	e.flushRender(sock)
+	if n := renders.Load() - before; n != 1 {
+		t.Errorf("expected nothing to flush, got %d renders", n)
+	}
+}
+
+func TestSocketCall(t *testing.T) {
+	h := testHandler()
+	h.HandleEventReply("read", func(ctx context.Context, s *Socket, p Params) (any, any, error) {
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		content, err := s.Call(ctx, "editor", "content", p)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, content, nil
+	})
+	h.HandleEvent("timeout", func(ctx context.Context, s *Socket, p Params) (any, error) {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err := s.Call(ctx, "editor", "content", nil)
+		return nil, err
+	})
+	_, wsURL := testServer(t, h)
+	c := testDial(t, wsURL, "call")
+	defer c.Close(websocket.StatusNormalClosure, "")
+
+	testWrite(t, c, Event{T: "read", ID: 1, Data: json.RawMessage(`{"format":"md"}`)})
+	ev := testRead(t, c)
+	for ev.T != EventCall {
+		ev = testRead(t, c)
+	}
+	var req call
+	if err := json.Unmarshal(ev.Data, &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Hook != "editor" || req.Event != "content" {
+		t.Fatalf("unexpected call %+v", req)
+	}
+	testWrite(t, c, Event{T: EventReply, ID: ev.ID, Data: json.RawMessage(`{"reply":"# hello"}`)})
+	for ev.T != EventAck {
+		ev = testRead(t, c)
+	}
+	if string(ev.Data) != `"# hello"` {
+		t.Errorf("expected reply in ack, got %s", ev.Data)
+	}
+
+	testWrite(t, c, Event{T: "timeout", ID: 2})
+	for ev.T != EventError {
+		ev = testRead(t, c)
+	}
+	var ee ErrorEvent
+	if err := json.Unmarshal(ev.Data, &ee); err != nil {
+		t.Fatal(err)
+	}
+	if ee.Err != context.DeadlineExceeded.Error() {
+		t.Errorf("expected deadline exceeded, got %s", ee.Err)
+	}
+}
+
+// testReplyCall replies to the next call read from the websocket.
+func testReplyCall(t *testing.T, c *websocket.Conn, reply string) {
+	t.Helper()
+	ev := testRead(t, c)
+	for ev.T != EventCall {
+		ev = testRead(t, c)
+	}
+	testWrite(t, c, Event{T: EventReply, ID: ev.ID, Data: json.RawMessage(`{"reply":"` + reply + `"}`)})
+}
+
+func TestSocketCallFromMount(t *testing.T) {
+	var got atomic.Value
+	h := testHandler()
+	h.MountHandler = func(ctx context.Context, s *Socket) (any, error) {
+		if !s.Connected() {
+			return nil, nil
+		}
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		content, err := s.Call(ctx, "editor", "content", nil)
+		if err != nil {
+			return nil, err
+		}
+		got.Store(string(content))
+		return nil, nil
+	}
+	_, wsURL := testServer(t, h)
+	c := testDial(t, wsURL, "call-mount")
+	defer c.Close(websocket.StatusNormalClosure, "")
+
+	testReplyCall(t, c, "mounted")
+	eventually(t, func() bool { return got.Load() == `"mounted"` })
+}
+
+func TestSocketCallBehindEvents(t *testing.T) {
+	h := testHandler()
+	h.HandleEventReply("read", func(ctx context.Context, s *Socket, p Params) (any, any, error) {
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		content, err := s.Call(ctx, "editor", "content", nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		return NoRender, content, nil
+	})
+	h.HandleEvent("noop", func(ctx context.Context, s *Socket, p Params) (any, error) {
+		return NoRender, nil
+	})
+	// Leave room for the acks of all of the events.
+	_, wsURL := testServer(t, h, WithOutboundQueue(OutboundQueue{Size: 4 * maxMessageBufferSize}))
+	c := testDial(t, wsURL, "call-behind")
+	defer c.Close(websocket.StatusNormalClosure, "")
+
+	// More events than can wait to be handled arrive before the reply.
+	testWrite(t, c, Event{T: "read", ID: 1})
+	for i := range maxMessageBufferSize + maxMessageBufferSize/2 {
+		testWrite(t, c, Event{T: "noop", ID: i + 2})
+	}
+	testReplyCall(t, c, "behind")
+	ev := testRead(t, c)
+	for ev.T != EventAck || ev.ID != 1 {
+		ev = testRead(t, c)
+	}
+	if string(ev.Data) != `"behind"` {
+		t.Errorf("expected reply in ack, got %s", ev.Data)
+	}
+}
+
+func TestSocketCallInboundLimit(t *testing.T) {
+	h := testHandler()
+	h.HandleEvent("read", func(ctx context.Context, s *Socket, p Params) (any, error) {
+		_, err := s.Call(ctx, "editor", "content", nil)
+		return nil, err
+	})
+	h.HandleEvent("noop", func(ctx context.Context, s *Socket, p Params) (any, error) {
+		return NoRender, nil
+	})
+	_, wsURL := testServer(t, h, WithOutboundQueue(OutboundQueue{Size: 4 * maxMessageBufferSize}))
+	c := testDial(t, wsURL, "call-limit")
+	defer c.Close(websocket.StatusNormalClosure, "")
+
+	// The call is never replied to, while events keep arriving.
+	testWrite(t, c, Event{T: "read", ID: 1})
+	go func() {
+		for i := range 4 * maxMessageBufferSize {
+			if err := c.Write(context.Background(), websocket.MessageText, []byte(fmt.Sprintf(`{"t":"noop","i":%d}`, i+2))); err != nil {
+				return
+			}
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		if _, _, err := c.Read(ctx); err != nil {
+			if websocket.CloseStatus(err) != websocket.StatusPolicyViolation {
+				t.Errorf("expected policy violation, got %v", err)
+			}
+			return
+		}
+	}
+}
+
+func TestPathParamsMount(t *testing.T) {
+	var got atomic.Value
+	h := testHandler()
+	h.MountHandler = func(ctx context.Context, s *Socket) (any, error) {
+		got.Store(s.PathParams())
+		return nil, nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /projects/{id}", NewHttpHandler(context.Background(), h))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/projects/7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if p := got.Load().(Params); p.String("id") != "7" {
+		t.Errorf("expected id on GET, got %v", p)
+	}
+
+	got.Store(Params{})
+	c := testDial(t, "ws"+strings.TrimPrefix(srv.URL, "http")+"/projects/8", "path")
+	defer c.Close(websocket.StatusNormalClosure, "")
+	eventually(t, func() bool { return got.Load().(Params).String("id") == "8" })
+}
@@ -16,3 +16,39 @@ var ErrNoSocket = errors.New("no socket")
 
 // ErrNotImplemented returned when an interface has not been implemented correctly.
 var ErrNotImplemented = errors.New("not implemented")
+
+// ErrSocketExpired returned when a detached socket passed its grace period before it could be resumed.
+var ErrSocketExpired = errors.New("socket expired")
+
+// ErrInvalidSocketID returned when a socket ID from the client is unsigned, tampered with or expired.
+var ErrInvalidSocketID = errors.New("invalid socket id")
+
+// ErrForbidden returned when a request fails an origin or CSRF check.
+var ErrForbidden = errors.New("forbidden")
+
+// ErrMaxSockets returned when an engine has accepted its maximum number of websocket connections.
+var ErrMaxSockets = errors.New("maximum sockets reached")
+
+// ErrMaxSocketsPerKey returned when a key has reached its maximum number of websocket connections.
+var ErrMaxSocketsPerKey = errors.New("maximum sockets for key reached")
+
+// ErrUnauthorized returned when a request or socket fails authentication.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// ErrNotConnected returned when a socket needs a websocket connection but doesn't have one.
+var ErrNotConnected = errors.New("socket not connected")
+
+// ErrCallFailed returned when a client hook fails to handle a call.
+var ErrCallFailed = errors.New("hook call failed")
+
+// ErrSocketUnmounted the cause of a sockets context ending when it is unmounted.
+var ErrSocketUnmounted = errors.New("socket unmounted")
+
+// ErrSocketDisconnected the cause of a sockets context ending when its client disconnects.
+var ErrSocketDisconnected = errors.New("socket disconnected")
+
+// ErrEventSuperseded the cause of an event handlers context ending when a newer event of the same name arrives, see CancelPrevious.
+var ErrEventSuperseded = errors.New("event superseded")
+
+// ErrOutboundFull returned when a message can't be sent as the socket's outbound queue is full.
+var ErrOutboundFull = errors.New("outbound queue full")
@@ -23,6 +23,15 @@ const (
 	// EventRedirect sent in order to trigger a browser
 	// redirect.
 	EventRedirect = "redirect"
+	// EventCall sent to call a hook on the client.
+	EventCall = "call"
+	// EventReply sent by the client in reply to a call.
+	EventReply = "reply"
+	// EventExec sent to execute JS commands on the client.
+	EventExec = "exec"
+	// EventSocketID sent with a freshly signed socket ID, for the
+	// client to use when it reconnects.
+	EventSocketID = "id"
 )
 
 // Event messages that are sent and received by the
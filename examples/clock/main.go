@@ -16,14 +16,25 @@ const (
 )
 
 type clock struct {
-	Time time.Time
+	Time     time.Time
+	Timezone string
+	// loc the loaded Timezone. It isn't serialised with the socket state,
+	// so it is loaded again when the clock is restored.
+	loc *time.Location
 }
 
 func newClock(s *live.Socket) *clock {
 	c, ok := s.Assigns().(*clock)
 	if !ok {
 		return &clock{
-			Time: time.Now(),
+			Time:     time.Now(),
+			Timezone: "UTC",
+			loc:      time.UTC,
+		}
+	}
+	if c.loc == nil {
+		if err := c.setTimezone(c.Timezone); err != nil {
+			c.Timezone, c.loc = "UTC", time.UTC
 		}
 	}
 	return c
@@ -33,18 +44,34 @@ func (c clock) FormattedTime() string {
 	return c.Time.Format("15:04:05")
 }
 
+// setTimezone loads the timezone the clock shows.
+func (c *clock) setTimezone(tz string) error {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return err
+	}
+	c.Timezone, c.loc = tz, loc
+	return nil
+}
+
 func mount(ctx context.Context, s *live.Socket) (any, error) {
 	// Take the socket data and tranform it into our view model if it is
 	// available.
 	c := newClock(s)
 
-	// If we are mouting the websocket connection, trigger the first tick
-	// event.
+	// Once connected the client tells us its timezone, so the clock can
+	// show its local time.
+	if s.Connected() {
+		if tz := s.ConnectParams().String("timezone"); tz != "" {
+			c.setTimezone(tz)
+		}
+	}
+	c.Time = c.Time.In(c.loc)
+
+	// If we are mouting the websocket connection, tick every second until
+	// the socket is unmounted.
 	if s.Connected() {
-		go func() {
-			time.Sleep(1 * time.Second)
-			s.Self(ctx, tick, time.Now())
-		}()
+		s.Interval(time.Second, tick, nil)
 	}
 	return c, nil
 }
@@ -67,12 +94,7 @@ func main() {
 		// Get our model
 		c := newClock(s)
 		// Update the time.
-		c.Time = d.(time.Time)
-		// Send ourselves another tick in a second.
-		go func(sock *live.Socket) {
-			time.Sleep(1 * time.Second)
-			sock.Self(ctx, tick, time.Now())
-		}(s)
+		c.Time = time.Now().In(c.loc)
 		return c, nil
 	})
 
@@ -48,20 +48,14 @@ func NewClockState(timezone string) (*ClockState, error) {
 
 // clockRegister register the clocks events.
 func clockRegister(c *page.Component) error {
-	// The clock listens for a tick event, then sends a new one after a second. On this
-	// event it updates its own time.
+	// The clock listens for a tick event, sent every second. On this event it updates
+	// its own time.
 	c.HandleSelf(tick, func(ctx context.Context, d any) (any, error) {
 		clock, ok := c.State.(*ClockState)
 		if !ok {
 			return nil, fmt.Errorf("no clock data")
 		}
-		clock.Update(d.(time.Time))
-
-		go func(sock *live.Socket) {
-			time.Sleep(1 * time.Second)
-			c.Self(ctx, sock, tick, time.Now())
-		}(c.Socket)
-
+		clock.Update(time.Now())
 		return clock, nil
 	})
 	return nil
@@ -70,12 +64,10 @@ func clockRegister(c *page.Component) error {
 // clockMount initialise the clock component.
 func clockMount(timezone string) page.MountHandler {
 	return func(ctx context.Context, c *page.Component) error {
-		// If we are mounting on connection send the first tick event.
+		// If we are mounting on connection tick every second until the socket
+		// is unmounted.
 		if c.Socket.Connected() {
-			go func() {
-				time.Sleep(1 * time.Second)
-				c.Self(ctx, c.Socket, tick, time.Now())
-			}()
+			c.Interval(c.Socket, time.Second, tick, nil)
 		}
 		state, err := NewClockState(timezone)
 		if err != nil {
@@ -1,8 +1,6 @@
 module github.com/jfyne/live/examples
 
-go 1.24
-
-toolchain go1.24.0
+go 1.24.0
 
 require (
 	github.com/jfyne/live v0.15.5
@@ -0,0 +1,203 @@
+package live
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// EventFlash sent to the client with a signed flash to keep in
+	// a cookie across a redirect.
+	EventFlash = "flash"
+
+	// cookieFlash name for a cookie which holds a signed flash.
+	cookieFlash = "_pflash"
+
+	// flashTTL how long a signed flash is accepted for.
+	flashTTL = time.Minute
+)
+
+// Flash messages to show the user, by kind, for example "info" or "error".
+type Flash map[string]string
+
+// Get the message of a kind.
+func (f Flash) Get(kind string) string {
+	return f[kind]
+}
+
+// WithFlashKey set the key used to sign flash messages which are carried
+// across redirects. By default the key is derived from the socket ID signers
+// current key, or without a signer a random key is generated, in which case
+// all nodes serving the handler must be given the same key.
+func WithFlashKey(key []byte) EngineConfig {
+	return func(e *Engine) error {
+		if len(key) < 32 {
+			return fmt.Errorf("flash key must be at least 32 bytes")
+		}
+		e.flashKey = key
+		return nil
+	}
+}
+
+// defaultFlashKey derives the flash key from the socket ID signer, so that
+// nodes sharing a signer can verify each others flashes. Otherwise a random
+// key is generated, which is only warned about when the engine shares its
+// socket state with other nodes.
+func (e *Engine) defaultFlashKey(sharedState bool) []byte {
+	if e.socketIDSigner != nil {
+		m := hmac.New(sha256.New, e.socketIDSigner.keys[0])
+		m.Write([]byte("flash key"))
+		return m.Sum(nil)
+	}
+	if sharedState {
+		slog.Warn("flash key is random, set WithFlashKey so flashes can be verified by other nodes")
+	}
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
+
+// PutFlash adds a flash message of a kind, replacing any message already of
+// that kind. The flash is available on the RenderContext, and is cleared once
+// it has been rendered by the connected socket. It is carried across a
+// Redirect or PushNavigate.
+func (s *Socket) PutFlash(kind, msg string) {
+	s.updateState(func(state *SocketState) {
+		f := maps.Clone(state.Flash)
+		if f == nil {
+			f = Flash{}
+		}
+		f[kind] = msg
+		state.Flash = f
+	})
+}
+
+// ClearFlash clears the flash messages of the kinds, or all of them if no
+// kinds are given.
+func (s *Socket) ClearFlash(kinds ...string) {
+	s.updateState(func(state *SocketState) {
+		if len(kinds) == 0 {
+			state.Flash = nil
+			return
+		}
+		f := maps.Clone(state.Flash)
+		for _, k := range kinds {
+			delete(f, k)
+		}
+		state.Flash = f
+	})
+}
+
+// clearShownFlash clears the flash messages which have been rendered, unless
+// they have been replaced since.
+func (s *Socket) clearShownFlash(shown Flash) {
+	s.updateState(func(state *SocketState) {
+		f := maps.Clone(state.Flash)
+		for k, msg := range shown {
+			if f[k] == msg {
+				delete(f, k)
+			}
+		}
+		state.Flash = f
+	})
+}
+
+// Flash returns the sockets flash messages.
+func (s *Socket) Flash() Flash {
+	state, _ := s.engine.socketStateStore.Get(s.id)
+	return maps.Clone(state.Flash)
+}
+
+// mergeFlash adds flash messages carried from elsewhere.
+func (s *Socket) mergeFlash(f Flash) {
+	if len(f) == 0 {
+		return
+	}
+	s.updateState(func(state *SocketState) {
+		merged := maps.Clone(state.Flash)
+		if merged == nil {
+			merged = Flash{}
+		}
+		maps.Copy(merged, f)
+		state.Flash = merged
+	})
+}
+
+// takeFlash signs the sockets flash so that it can be carried across a
+// redirect or navigation, and clears it.
+func (s *Socket) takeFlash() string {
+	var f Flash
+	s.updateState(func(state *SocketState) {
+		f, state.Flash = state.Flash, nil
+	})
+	if len(f) == 0 {
+		return ""
+	}
+	return s.engine.signFlash(f)
+}
+
+// signedFlash the payload of a signed flash.
+type signedFlash struct {
+	Flash  Flash `json:"f"`
+	Issued int64 `json:"t"`
+}
+
+// signFlash signs a flash as "<b64url json>.<b64url hmac-sha256>".
+func (e *Engine) signFlash(f Flash) string {
+	d, err := json.Marshal(signedFlash{Flash: f, Issued: time.Now().Unix()})
+	if err != nil {
+		return ""
+	}
+	payload := base64.RawURLEncoding.EncodeToString(d)
+	return payload + "." + e.flashMAC(payload)
+}
+
+// verifyFlash verifies a signed flash.
+func (e *Engine) verifyFlash(signed string) (Flash, error) {
+	payload, sig, ok := strings.Cut(signed, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(e.flashMAC(payload))) {
+		return nil, fmt.Errorf("flash signature invalid")
+	}
+	d, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("flash malformed: %w", err)
+	}
+	var sf signedFlash
+	if err := json.Unmarshal(d, &sf); err != nil {
+		return nil, fmt.Errorf("flash malformed: %w", err)
+	}
+	if time.Now().After(time.Unix(sf.Issued, 0).Add(flashTTL)) {
+		return nil, fmt.Errorf("flash expired")
+	}
+	return sf.Flash, nil
+}
+
+func (e *Engine) flashMAC(payload string) string {
+	m := hmac.New(sha256.New, e.flashKey)
+	m.Write([]byte("flash:" + payload))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+// flashFromRequest reads the flash carried across a redirect in a cookie, and
+// deletes the cookie.
+func (e *Engine) flashFromRequest(w http.ResponseWriter, r *http.Request) Flash {
+	c, err := r.Cookie(cookieFlash)
+	if err != nil {
+		return nil
+	}
+	http.SetCookie(w, &http.Cookie{Name: cookieFlash, Path: "/", MaxAge: -1})
+	f, err := e.verifyFlash(c.Value)
+	if err != nil {
+		return nil
+	}
+	return f
+}
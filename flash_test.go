@@ -0,0 +1,152 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+func TestFlashSigning(t *testing.T) {
+	e := NewHttpHandler(t.Context(), NewHandler())
+	signed := e.signFlash(Flash{"info": "saved"})
+	f, err := e.verifyFlash(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Get("info") != "saved" {
+		t.Errorf("unexpected flash %v", f)
+	}
+	if _, err := e.verifyFlash(signed + "x"); err == nil {
+		t.Error("expected tampered flash to be rejected")
+	}
+	other := NewHttpHandler(t.Context(), NewHandler())
+	if _, err := other.verifyFlash(signed); err == nil {
+		t.Error("expected flash signed with another key to be rejected")
+	}
+}
+
+func TestFlashKeyFromSigner(t *testing.T) {
+	signer, err := NewSocketIDSigner(time.Minute, []byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewHttpHandler(t.Context(), NewHandler(), WithSocketIDSigner(signer))
+	b := NewHttpHandler(t.Context(), NewHandler(), WithSocketIDSigner(signer))
+	f, err := b.verifyFlash(a.signFlash(Flash{"info": "saved"}))
+	if err != nil {
+		t.Fatalf("expected nodes sharing a signer to share flashes, got %v", err)
+	}
+	if f.Get("info") != "saved" {
+		t.Errorf("unexpected flash %v", f)
+	}
+}
+
+func TestFlashRedirect(t *testing.T) {
+	h := testView(func(rc *RenderContext) string { return "<p>" + rc.Flash.Get("info") + "</p>" })
+	h.HandleEvent("save", func(ctx context.Context, s *Socket, p Params) (any, error) {
+		s.PutFlash("info", "saved")
+		s.Redirect(&url.URL{Path: "/done"})
+		return nil, nil
+	})
+	e, wsURL := testServer(t, h)
+	c := testDial(t, wsURL, "flash")
+	defer c.Close(websocket.StatusNormalClosure, "")
+
+	testWrite(t, c, Event{T: "save", ID: 1})
+	ev := testRead(t, c)
+	for ev.T == EventPatch {
+		ev = testRead(t, c)
+	}
+	if ev.T != EventFlash {
+		t.Fatalf("expected flash event, got %s", ev.T)
+	}
+	var signed string
+	if err := json.Unmarshal(ev.Data, &signed); err != nil {
+		t.Fatal(err)
+	}
+	if ev := testRead(t, c); ev.T != EventRedirect {
+		t.Fatalf("expected redirect event, got %s", ev.T)
+	}
+
+	// The next page load renders the flash carried in the cookie.
+	req := httptest.NewRequest("GET", "/done", nil)
+	req.AddCookie(&http.Cookie{Name: cookieFlash, Value: signed})
+	rr := httptest.NewRecorder()
+	e.ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), `="">saved</p>`) {
+		t.Errorf("expected flash to be rendered, got %s", rr.Body.String())
+	}
+	if !strings.Contains(rr.Header().Get("Set-Cookie"), cookieFlash+"=;") {
+		t.Errorf("expected flash cookie to be deleted, got %s", rr.Header().Get("Set-Cookie"))
+	}
+}
+
+func TestFlashClearedAfterRender(t *testing.T) {
+	h := testView(func(rc *RenderContext) string { return "<p>" + rc.Flash.Get("info") + "</p>" })
+	h.HandleEvent("put", func(ctx context.Context, s *Socket, p Params) (any, error) {
+		s.PutFlash("info", "hello")
+		return nil, nil
+	})
+	h.HandleEvent("noop", func(ctx context.Context, s *Socket, p Params) (any, error) {
+		return nil, nil
+	})
+	_, wsURL := testServer(t, h)
+	c := testDial(t, wsURL, "flash-clear")
+	defer c.Close(websocket.StatusNormalClosure, "")
+
+	testWrite(t, c, Event{T: "put", ID: 1})
+	ev := testRead(t, c)
+	for ev.T != EventPatch {
+		ev = testRead(t, c)
+	}
+	if !strings.Contains(string(ev.Data), "hello") {
+		t.Fatalf("expected flash in patch, got %s", ev.Data)
+	}
+	if ev := testRead(t, c); ev.T != EventAck {
+		t.Fatalf("expected ack, got %s", ev.T)
+	}
+
+	testWrite(t, c, Event{T: "noop", ID: 2})
+	ev = testRead(t, c)
+	if ev.T != EventPatch || strings.Contains(string(ev.Data), "hello") {
+		t.Fatalf("expected flash to be cleared, got %s %s", ev.T, ev.Data)
+	}
+}
+
+func TestFlashKeptWhenPatchNotSent(t *testing.T) {
+	e := NewHttpHandler(t.Context(), testView(func(rc *RenderContext) string { return "<p>" + rc.Flash.Get("info") + "</p>" }), WithOutboundQueue(OutboundQueue{Size: 1}))
+	s := NewSocket(t.Context(), e, "flash-full")
+	defer s.close()
+	render, err := RenderSocket(t.Context(), e, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.UpdateRender(render)
+
+	s.PutFlash("info", "hello")
+	if err := s.Send("filler", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RenderSocket(t.Context(), e, s); !errors.Is(err, ErrOutboundFull) {
+		t.Fatalf("expected full queue error, got %v", err)
+	}
+	if s.Flash().Get("info") != "hello" {
+		t.Errorf("expected flash to be kept until it is sent, got %v", s.Flash())
+	}
+
+	<-s.Messages()
+	if _, err := RenderSocket(t.Context(), e, s); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Flash()) != 0 {
+		t.Errorf("expected flash to be cleared once sent, got %v", s.Flash())
+	}
+}
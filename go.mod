@@ -1,8 +1,6 @@
 module github.com/jfyne/live
 
-go 1.23.0
-
-toolchain go1.24.0
+go 1.24.0
 
 require (
 	github.com/coder/websocket v1.8.13
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"sync"
 )
 
 //var _ Handler = &BaseHandler{}
@@ -35,10 +36,69 @@ type ErrorHandler func(ctx context.Context, err error)
 // be set to the socket after handling.
 type EventHandler func(context.Context, *Socket, Params) (any, error)
 
+// ReplyHandler a function to handle events which reply to the client, returns
+// the data that should be set to the socket after handling and a reply which is
+// sent back to the client with the events ack.
+type ReplyHandler func(context.Context, *Socket, Params) (data any, reply any, err error)
+
 // SelfHandler a function to handle self events, returns the data that should
 // be set to the socket after handling.
 type SelfHandler func(context.Context, *Socket, any) (any, error)
 
+// InvocationKind the kind of handler being invoked.
+type InvocationKind int
+
+const (
+	// InvokeEvent an EventHandler handling a client event.
+	InvokeEvent InvocationKind = iota
+	// InvokeSelf a SelfHandler handling a server side event.
+	InvokeSelf
+	// InvokeParams a params handler handling a URL parameter change.
+	InvokeParams
+)
+
+// Invocation describes a call to an event, self or params handler.
+type Invocation struct {
+	// Kind of handler being invoked.
+	Kind InvocationKind
+	// Event the name of the event being handled, for params handlers
+	// this is EventParams.
+	Event string
+	// Socket the event is being handled for.
+	Socket *Socket
+	// Params of a client event or params change.
+	Params Params
+	// Data of a self event.
+	Data any
+}
+
+// InvokeFunc calls a handler, or the next middleware in the chain.
+type InvokeFunc func(ctx context.Context, inv *Invocation) (any, error)
+
+// Middleware wraps the invocation of every event, self and params handler. It
+// can inspect the invocation, and choose whether or not to call next.
+type Middleware func(next InvokeFunc) InvokeFunc
+
+// EventOption configures how a client event is handled.
+type EventOption func(*eventConfig)
+
+// eventConfig how a client event is handled.
+type eventConfig struct {
+	cancelPrevious bool
+}
+
+// CancelPrevious handles the event latest wins, for example for search as you
+// type. If the event arrives while a previous one of the same name is still
+// being handled, the previous handlers context is cancelled with the cause
+// ErrEventSuperseded and its result is discarded. Events of the same name which
+// are still waiting to be handled are skipped. Skipped and discarded events are
+// still acknowledged.
+func CancelPrevious() EventOption {
+	return func(c *eventConfig) {
+		c.cancelPrevious = true
+	}
+}
+
 // Handler.
 type Handler struct {
 	// MountHandler a user should provide the mount function. This is what
@@ -55,16 +115,21 @@ type Handler struct {
 	ErrorHandler ErrorHandler
 	// eventHandlers the map of client event handlers.
 	eventHandlers map[string]EventHandler
+	// eventConfigs how client events are handled, by event.
+	eventConfigs map[string]eventConfig
 	// selfHandlers the map of handler event handlers.
 	selfHandlers map[string]SelfHandler
 	// paramsHandlers a slice of handlers which respond to a change in URL parameters.
 	paramsHandlers []EventHandler
+	// middleware wraps handler invocations.
+	middleware []Middleware
 }
 
 // NewHandler sets up a base handler for live.
 func NewHandler(configs ...HandlerConfig) *Handler {
 	h := &Handler{
 		eventHandlers:  make(map[string]EventHandler),
+		eventConfigs:   make(map[string]eventConfig),
 		selfHandlers:   make(map[string]SelfHandler),
 		paramsHandlers: []EventHandler{},
 		MountHandler: func(ctx context.Context, s *Socket) (any, error) {
@@ -94,8 +159,29 @@ func NewHandler(configs ...HandlerConfig) *Handler {
 
 // HandleEvent handles an event that comes from the client. For example a click
 // from `live-click="myevent"`.
-func (h *Handler) HandleEvent(t string, handler EventHandler) {
+func (h *Handler) HandleEvent(t string, handler EventHandler, options ...EventOption) {
+	var c eventConfig
+	for _, o := range options {
+		o(&c)
+	}
 	h.eventHandlers[t] = handler
+	h.eventConfigs[t] = c
+}
+
+// HandleEventReply handles an event that comes from the client and replies to
+// it. For example a hook calling `this.pushEvent(...)` receives the reply when
+// the returned promise resolves.
+func (h *Handler) HandleEventReply(t string, handler ReplyHandler, options ...EventOption) {
+	h.HandleEvent(t, func(ctx context.Context, s *Socket, p Params) (any, error) {
+		data, reply, err := handler(ctx, s, p)
+		if err != nil {
+			return data, err
+		}
+		if r, ok := ctx.Value(replyKey{}).(*any); ok {
+			*r = reply
+		}
+		return data, nil
+	}, options...)
 }
 
 // HandleSelf handles an event that comes from the server side socket. For example calling
@@ -110,6 +196,20 @@ func (h *Handler) HandleParams(handler EventHandler) {
 	h.paramsHandlers = append(h.paramsHandlers, handler)
 }
 
+// Use adds middleware which wraps every event, self and params handler. Middleware
+// is called in the order it is added.
+func (h *Handler) Use(middleware ...Middleware) {
+	h.middleware = append(h.middleware, middleware...)
+}
+
+// invoke calls a handler through the middleware chain.
+func (h *Handler) invoke(ctx context.Context, inv *Invocation, handler InvokeFunc) (any, error) {
+	for i := len(h.middleware) - 1; i >= 0; i-- {
+		handler = h.middleware[i](handler)
+	}
+	return handler(ctx, inv)
+}
+
 func (h *Handler) getEvent(t string) (EventHandler, error) {
 	handler, ok := h.eventHandlers[t]
 	if !ok {
@@ -117,6 +217,12 @@ func (h *Handler) getEvent(t string) (EventHandler, error) {
 	}
 	return handler, nil
 }
+
+// cancelsPrevious is the event handled latest wins.
+func (h *Handler) cancelsPrevious(t string) bool {
+	return h.eventConfigs[t].cancelPrevious
+}
+
 func (h *Handler) getSelf(t string) (SelfHandler, error) {
 	handler, ok := h.selfHandlers[t]
 	if !ok {
@@ -124,3 +230,71 @@ func (h *Handler) getSelf(t string) (SelfHandler, error) {
 	}
 	return handler, nil
 }
+
+// latestEvents tracks the events from a websocket which are handled latest
+// wins, by name. It lives as long as the connection, so that nothing is left
+// over if the connection ends with events waiting.
+type latestEvents struct {
+	mu     sync.Mutex
+	events map[string]*latestEvent
+}
+
+// latestEvent tracks an event which is handled latest wins.
+type latestEvent struct {
+	// pending events of this name waiting to be handled.
+	pending int
+	// cancel the event being handled.
+	cancel context.CancelCauseFunc
+}
+
+// queue records that an event handled latest wins is waiting to be
+// handled, and cancels the one of the same name being handled.
+func (l *latestEvents) queue(t string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.events == nil {
+		l.events = map[string]*latestEvent{}
+	}
+	ev, ok := l.events[t]
+	if !ok {
+		ev = &latestEvent{}
+		l.events[t] = ev
+	}
+	ev.pending++
+	if ev.cancel != nil {
+		ev.cancel(ErrEventSuperseded)
+	}
+}
+
+// start starts handling an event. If it is handled latest wins the
+// context is cancelled when a newer one arrives, and false is returned if a
+// newer one is already waiting.
+func (l *latestEvents) start(ctx context.Context, t string) (context.Context, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ev, ok := l.events[t]
+	if !ok {
+		return ctx, true
+	}
+	ev.pending--
+	if ev.pending > 0 {
+		return ctx, false
+	}
+	ctx, ev.cancel = context.WithCancelCause(ctx)
+	return ctx, true
+}
+
+// end finishes handling an event.
+func (l *latestEvents) end(t string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ev, ok := l.events[t]
+	if !ok || ev.cancel == nil {
+		return
+	}
+	ev.cancel(nil)
+	ev.cancel = nil
+	if ev.pending == 0 {
+		delete(l.events, t)
+	}
+}
@@ -2,6 +2,7 @@ package live
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -32,8 +33,13 @@ func TestHandler(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
 		return
 	}
-	if rr.Body.String() != output {
-		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), output)
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("handler did not set socket cookie: %v", cookies)
+	}
+	expected := strings.Replace(output, `live-rendered=""`, `live-rendered="`+cookies[0].Value+`"`, 1)
+	if rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
 	}
 }
 
@@ -56,3 +62,62 @@ func TestHandlerErrorNoRenderer(t *testing.T) {
 		return
 	}
 }
+
+func TestHandlerMiddleware(t *testing.T) {
+	h := NewHandler()
+	e := NewHttpHandler(context.Background(), h)
+	sock := NewSocket(context.Background(), e, "")
+	defer sock.close()
+
+	calls := []string{}
+	h.Use(func(next InvokeFunc) InvokeFunc {
+		return func(ctx context.Context, inv *Invocation) (any, error) {
+			calls = append(calls, "first:"+inv.Event)
+			return next(ctx, inv)
+		}
+	}, func(next InvokeFunc) InvokeFunc {
+		return func(ctx context.Context, inv *Invocation) (any, error) {
+			calls = append(calls, "second:"+inv.Event)
+			if inv.Kind == InvokeEvent && inv.Params.String("deny") != "" {
+				return nil, errors.New("denied")
+			}
+			return next(ctx, inv)
+		}
+	})
+	h.HandleEvent("click", func(ctx context.Context, s *Socket, p Params) (any, error) {
+		calls = append(calls, "click")
+		return nil, nil
+	})
+	h.HandleSelf("tick", func(ctx context.Context, s *Socket, d any) (any, error) {
+		calls = append(calls, "tick")
+		return nil, nil
+	})
+	h.HandleParams(func(ctx context.Context, s *Socket, p Params) (any, error) {
+		calls = append(calls, "params")
+		return nil, nil
+	})
+
+	ctx := context.Background()
+	if err := e.CallEvent(ctx, "click", sock, Event{T: "click"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.handleSelf(ctx, "tick", sock, Event{T: "tick"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.CallParams(ctx, sock, Event{T: EventParams}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.CallEvent(ctx, "click", sock, Event{T: "click", Data: []byte(`{"deny":"yes"}`)}); err == nil {
+		t.Error("expected middleware to deny event")
+	}
+
+	expected := []string{
+		"first:click", "second:click", "click",
+		"first:tick", "second:tick", "tick",
+		"first:params", "second:params", "params",
+		"first:click", "second:click",
+	}
+	if strings.Join(calls, ",") != strings.Join(expected, ",") {
+		t.Errorf("unexpected middleware calls got %v want %v", calls, expected)
+	}
+}
@@ -0,0 +1,115 @@
+package live
+
+import (
+	"encoding/json"
+)
+
+// JSCommands a list of commands which the client executes without a round
+// trip to the server. It can be used in place of an event name in an event
+// binding, for example
+//
+//	<button live-click="{{ .Menu }}">Menu</button>
+//
+// where `Menu` is
+//
+//	live.JSCommands{}.Toggle("#menu").AddClass("", "active")
+//
+// Commands target the elements matching their CSS selector, or the element
+// with the binding if the selector is empty. State set by commands is kept
+// when the targeted elements are patched, until the server renders a change
+// to it. It isn't called JS as that is the client bundle.
+type JSCommands struct {
+	cmds []jsCommand
+}
+
+// jsCommand a single client command.
+type jsCommand struct {
+	Op      string   `json:"op"`
+	To      string   `json:"to,omitempty"`
+	Classes []string `json:"classes,omitempty"`
+	Attr    string   `json:"attr,omitempty"`
+	Value   any      `json:"value,omitempty"`
+	Event   string   `json:"event,omitempty"`
+}
+
+// with returns a copy of the commands with the command added, so that
+// commands can be built up from a shared base.
+func (j JSCommands) with(cmd jsCommand) JSCommands {
+	cmds := make([]jsCommand, len(j.cmds), len(j.cmds)+1)
+	copy(cmds, j.cmds)
+	return JSCommands{cmds: append(cmds, cmd)}
+}
+
+// Show the elements.
+func (j JSCommands) Show(to string) JSCommands {
+	return j.with(jsCommand{Op: "show", To: to})
+}
+
+// Hide the elements.
+func (j JSCommands) Hide(to string) JSCommands {
+	return j.with(jsCommand{Op: "hide", To: to})
+}
+
+// Toggle show the elements if they are hidden, otherwise hide them.
+func (j JSCommands) Toggle(to string) JSCommands {
+	return j.with(jsCommand{Op: "toggle", To: to})
+}
+
+// AddClass add classes to the elements.
+func (j JSCommands) AddClass(to string, classes ...string) JSCommands {
+	return j.with(jsCommand{Op: "add_class", To: to, Classes: classes})
+}
+
+// RemoveClass remove classes from the elements.
+func (j JSCommands) RemoveClass(to string, classes ...string) JSCommands {
+	return j.with(jsCommand{Op: "remove_class", To: to, Classes: classes})
+}
+
+// SetAttribute set an attribute on the elements.
+func (j JSCommands) SetAttribute(to, attr, value string) JSCommands {
+	return j.with(jsCommand{Op: "set_attr", To: to, Attr: attr, Value: value})
+}
+
+// RemoveAttribute remove an attribute from the elements.
+func (j JSCommands) RemoveAttribute(to, attr string) JSCommands {
+	return j.with(jsCommand{Op: "remove_attr", To: to, Attr: attr})
+}
+
+// Focus the first of the elements.
+func (j JSCommands) Focus(to string) JSCommands {
+	return j.with(jsCommand{Op: "focus", To: to})
+}
+
+// Dispatch a DOM CustomEvent on the elements, with the detail.
+func (j JSCommands) Dispatch(to, event string, detail any) JSCommands {
+	return j.with(jsCommand{Op: "dispatch", To: to, Event: event, Value: detail})
+}
+
+// Push an event to the server, as if it were bound directly. The params
+// are merged with the `live-value-*` params of the element with the binding.
+func (j JSCommands) Push(event string, params Params) JSCommands {
+	return j.with(jsCommand{Op: "push", Event: event, Value: params})
+}
+
+// MarshalJSON encodes the commands as sent to the client.
+func (j JSCommands) MarshalJSON() ([]byte, error) {
+	if j.cmds == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(j.cmds)
+}
+
+// String the commands ready to be used as a binding attribute value.
+func (j JSCommands) String() string {
+	d, err := j.MarshalJSON()
+	if err != nil {
+		return "[]"
+	}
+	return string(d)
+}
+
+// Exec sends commands to the client to execute. Commands with an empty
+// selector are ignored as there is no binding element.
+func (s *Socket) Exec(js JSCommands) error {
+	return s.Send(EventExec, js)
+}
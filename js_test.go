@@ -0,0 +1,38 @@
+package live
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+)
+
+func TestJS(t *testing.T) {
+	base := JSCommands{}.Toggle("#menu")
+	open := base.AddClass("", "active", "open")
+	push := base.Push("inc", Params{"id": 1})
+
+	if got, want := open.String(), `[{"op":"toggle","to":"#menu"},{"op":"add_class","classes":["active","open"]}]`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if got, want := push.String(), `[{"op":"toggle","to":"#menu"},{"op":"push","value":{"id":1},"event":"inc"}]`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if got, want := base.String(), `[{"op":"toggle","to":"#menu"}]`; got != want {
+		t.Errorf("base modified, got %s", got)
+	}
+	if got := (JSCommands{}).String(); got != "[]" {
+		t.Errorf("expected empty commands, got %s", got)
+	}
+}
+
+func TestJSTemplate(t *testing.T) {
+	tmpl := template.Must(template.New("").Parse(`<button live-click="{{ . }}"></button>`))
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, JSCommands{}.SetAttribute("#x", "title", `"><script>`)); err != nil {
+		t.Fatal(err)
+	}
+	want := `<button live-click="[{&#34;op&#34;:&#34;set_attr&#34;,&#34;to&#34;:&#34;#x&#34;,&#34;attr&#34;:&#34;title&#34;,&#34;value&#34;:&#34;\&#34;\u003e\u003cscript\u003e&#34;}]"></button>`
+	if buf.String() != want {
+		t.Errorf("got %s", buf.String())
+	}
+}
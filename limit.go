@@ -0,0 +1,141 @@
+package live
+
+import (
+	"net"
+	"net/http"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// LimitPolicy decides what happens when a client sends events faster than
+// its limit allows.
+type LimitPolicy int
+
+const (
+	// LimitDrop drop the event. The event is still acknowledged so that the
+	// client doesn't wait on it.
+	LimitDrop LimitPolicy = iota
+	// LimitDisconnect close the websocket.
+	LimitDisconnect
+)
+
+// EventLimits limits on the events a client can send over its websocket.
+type EventLimits struct {
+	// PerSocket the rate of events allowed from a single socket. Zero
+	// means no limit.
+	PerSocket rate.Limit
+	// PerSocketBurst the number of events a socket can send at once.
+	// Defaults to 1.
+	PerSocketBurst int
+	// PerIP the rate of events allowed from all sockets connected from
+	// the same IP. Zero means no limit.
+	PerIP rate.Limit
+	// PerIPBurst the number of events an IP can send at once. Defaults
+	// to 1.
+	PerIPBurst int
+	// PerIPKey gets the key sockets share the PerIP limit by. Defaults to
+	// the client IP taken from the connection, X-Forwarded-For is not
+	// trusted, so behind a proxy use a key which reads the header it sets.
+	PerIPKey AdmissionKeyFunc
+	// Policy what to do with events over the limit.
+	Policy LimitPolicy
+	// UnknownEventThreshold the number of events without a handler a
+	// socket can send before it is disconnected. Zero means no limit.
+	UnknownEventThreshold int
+}
+
+// WithEventLimits limit the events clients can send to the engine.
+func WithEventLimits(limits EventLimits) EngineConfig {
+	return func(e *Engine) error {
+		if limits.PerIPKey == nil {
+			limits.PerIPKey = remoteIPKey
+		}
+		// A limiter without a burst allows nothing.
+		if limits.PerSocketBurst < 1 {
+			limits.PerSocketBurst = 1
+		}
+		if limits.PerIPBurst < 1 {
+			limits.PerIPBurst = 1
+		}
+		e.eventLimits = limits
+		if limits.PerIP > 0 {
+			e.ipLimiters = &ipLimiters{
+				limit:   limits.PerIP,
+				burst:   limits.PerIPBurst,
+				clients: map[string]*ipLimiter{},
+			}
+		} else {
+			e.ipLimiters = nil
+		}
+		return nil
+	}
+}
+
+// allowEvent checks an inbound event against the socket and IP limits.
+func (e *Engine) allowEvent(sock *Socket, ip *rate.Limiter) bool {
+	if l := sock.eventLimiter(); l != nil && !l.Allow() {
+		return false
+	}
+	if ip != nil && !ip.Allow() {
+		return false
+	}
+	return true
+}
+
+// ipLimiters shares limiters between sockets connected from the same IP.
+type ipLimiters struct {
+	limit rate.Limit
+	burst int
+
+	mu      sync.Mutex
+	clients map[string]*ipLimiter
+}
+
+type ipLimiter struct {
+	limiter *rate.Limiter
+	sockets int
+}
+
+// acquire gets the limiter for an IP, it must be released when the
+// socket disconnects.
+func (l *ipLimiters) acquire(ip string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, ok := l.clients[ip]
+	if !ok {
+		c = &ipLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[ip] = c
+	}
+	c.sockets++
+	return c.limiter
+}
+
+// release an IPs limiter, once no sockets are using it it is removed.
+func (l *ipLimiters) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, ok := l.clients[ip]
+	if !ok {
+		return
+	}
+	c.sockets--
+	if c.sockets <= 0 {
+		delete(l.clients, ip)
+	}
+}
+
+// remoteIPKey keys a request by the IP it came from.
+func remoteIPKey(r *http.Request, principal any) string {
+	return remoteIP(r)
+}
+
+// remoteIP the IP a request came from. This is the address of the connection,
+// the X-Forwarded-For header is not trusted as any client can set it.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
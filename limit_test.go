@@ -0,0 +1,145 @@
+package live
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"golang.org/x/time/rate"
+)
+
+func TestIPLimiters(t *testing.T) {
+	l := &ipLimiters{limit: 1, burst: 1, clients: map[string]*ipLimiter{}}
+	a := l.acquire("1.2.3.4")
+	b := l.acquire("1.2.3.4")
+	if a != b {
+		t.Error("expected sockets from the same IP to share a limiter")
+	}
+	if l.acquire("5.6.7.8") == a {
+		t.Error("expected sockets from different IPs to have their own limiter")
+	}
+	l.release("1.2.3.4")
+	if _, ok := l.clients["1.2.3.4"]; !ok {
+		t.Error("limiter removed while still in use")
+	}
+	l.release("1.2.3.4")
+	if _, ok := l.clients["1.2.3.4"]; ok {
+		t.Error("limiter not removed once unused")
+	}
+}
+
+func TestEventLimitDrop(t *testing.T) {
+	var clicks atomic.Int32
+	h := testHandler()
+	h.HandleEvent("click", func(ctx context.Context, s *Socket, p Params) (any, error) {
+		clicks.Add(1)
+		return nil, nil
+	})
+	_, url := testServer(t, h, WithEventLimits(EventLimits{
+		PerSocket:      rate.Every(time.Hour),
+		PerSocketBurst: 1,
+		Policy:         LimitDrop,
+	}))
+
+	c := testDial(t, url, "drop")
+	defer c.Close(websocket.StatusNormalClosure, "")
+	for i := 1; i <= 3; i++ {
+		testWrite(t, c, Event{T: "click", ID: i})
+		if ev := testRead(t, c); ev.T != EventAck || ev.ID != i {
+			t.Fatalf("expected ack for %d, got %s %d", i, ev.T, ev.ID)
+		}
+	}
+	if clicks.Load() != 1 {
+		t.Errorf("expected events over the limit to be dropped, handled %d", clicks.Load())
+	}
+}
+
+func TestEventLimitDefaultBurst(t *testing.T) {
+	var clicks atomic.Int32
+	h := testHandler()
+	h.HandleEvent("click", func(ctx context.Context, s *Socket, p Params) (any, error) {
+		clicks.Add(1)
+		return nil, nil
+	})
+	_, url := testServer(t, h, WithEventLimits(EventLimits{
+		PerSocket: 10,
+		PerIP:     10,
+	}))
+
+	c := testDial(t, url, "default-burst")
+	defer c.Close(websocket.StatusNormalClosure, "")
+	testWrite(t, c, Event{T: "click", ID: 1})
+	for ev := testRead(t, c); ev.T != EventAck; ev = testRead(t, c) {
+	}
+	if clicks.Load() != 1 {
+		t.Errorf("expected event within the limit to be handled, handled %d", clicks.Load())
+	}
+}
+
+func TestEventLimitDisconnect(t *testing.T) {
+	for name, limits := range map[string]EventLimits{
+		"rate": {
+			PerIP:      rate.Every(time.Hour),
+			PerIPBurst: 1,
+			Policy:     LimitDisconnect,
+		},
+		"unknown": {
+			UnknownEventThreshold: 1,
+		},
+	} {
+		h := testHandler()
+		h.HandleEvent("click", func(ctx context.Context, s *Socket, p Params) (any, error) {
+			return nil, nil
+		})
+		_, url := testServer(t, h, WithEventLimits(limits))
+
+		c := testDial(t, url, SocketID(name))
+		event := "click"
+		if name == "unknown" {
+			event = "nope"
+		}
+		var err error
+		for i := 1; i <= 3 && err == nil; i++ {
+			testWrite(t, c, Event{T: event, ID: i})
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			_, _, err = c.Read(ctx)
+			cancel()
+		}
+		if websocket.CloseStatus(err) != websocket.StatusPolicyViolation {
+			t.Errorf("%s: expected policy violation close, got %v", name, err)
+		}
+	}
+}
+
+func TestEventLimitPerIPKey(t *testing.T) {
+	var clicks atomic.Int32
+	h := testHandler()
+	h.HandleEvent("click", func(ctx context.Context, s *Socket, p Params) (any, error) {
+		clicks.Add(1)
+		return nil, nil
+	})
+	_, url := testServer(t, h, WithEventLimits(EventLimits{
+		PerIP:      rate.Every(time.Hour),
+		PerIPBurst: 1,
+		PerIPKey: func(r *http.Request, principal any) string {
+			return r.URL.Query().Get("client")
+		},
+	}))
+
+	// Both sockets come from the same address, but are keyed apart.
+	for _, client := range []string{"a", "b"} {
+		c := testDial(t, url+"?client="+client, SocketID("key-"+client))
+		defer c.Close(websocket.StatusNormalClosure, "")
+		testWrite(t, c, Event{T: "click", ID: 1})
+		ev := testRead(t, c)
+		for ev.T != EventAck {
+			ev = testRead(t, c)
+		}
+	}
+	if clicks.Load() != 2 {
+		t.Errorf("expected each key to have its own limit, handled %d", clicks.Load())
+	}
+}
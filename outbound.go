@@ -0,0 +1,186 @@
+package live
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultWriteTimeout how long writing a message to a websocket can take.
+	defaultWriteTimeout = 5 * time.Second
+)
+
+// OutboundPolicy decides what happens when a socket's outbound queue is full,
+// because its client can't keep up with the messages sent to it.
+type OutboundPolicy int
+
+const (
+	// OutboundDisconnect close the websocket.
+	OutboundDisconnect OutboundPolicy = iota
+	// OutboundMergePatches merge consecutive queued patches into one message
+	// to make room, disconnecting if that isn't enough.
+	OutboundMergePatches
+	// OutboundDropStale merge consecutive queued patches, and drop the oldest
+	// queued event of the same type as the one being sent, as it is stale.
+	// Events with an ID such as acks are never stale. If there is still no
+	// room the message is dropped, even if it is an ack, and the socket stays
+	// connected.
+	OutboundDropStale
+)
+
+// OutboundQueue configures the queue of messages waiting to be written to a
+// socket's websocket. While a socket is waiting to be resumed its full queue
+// is handled as OutboundDropStale, whatever the policy.
+type OutboundQueue struct {
+	// Size the number of messages that can be waiting. Defaults to 16.
+	Size int
+	// WriteTimeout how long writing a message can take before the websocket
+	// is closed. Defaults to 5 seconds.
+	WriteTimeout time.Duration
+	// Policy what to do when the queue is full.
+	Policy OutboundPolicy
+}
+
+// WithOutboundQueue configure the queue of messages waiting to be written to
+// each socket's websocket.
+func WithOutboundQueue(q OutboundQueue) EngineConfig {
+	return func(e *Engine) error {
+		if q.Size < 0 || q.WriteTimeout < 0 {
+			return fmt.Errorf("outbound queue size and write timeout must not be negative")
+		}
+		e.outbound = q
+		return nil
+	}
+}
+
+// outboundSize the size of each socket's outbound queue.
+func (e *Engine) outboundSize() int {
+	if e.outbound.Size <= 0 {
+		return maxMessageBufferSize
+	}
+	return e.outbound.Size
+}
+
+// writeTimeout how long writing a message to a websocket can take.
+func (e *Engine) writeTimeout() time.Duration {
+	if e.outbound.WriteTimeout <= 0 {
+		return defaultWriteTimeout
+	}
+	return e.outbound.WriteTimeout
+}
+
+// enqueue puts a message on the socket's outbound queue, applying the
+// engine's outbound policy if it is full.
+func (s *Socket) enqueue(msg Event) error {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	select {
+	case s.msgs <- msg:
+		return nil
+	default:
+	}
+
+	s.mu.Lock()
+	detached := s.detachTimer != nil
+	closeSlow := s.closeSlow
+	s.mu.Unlock()
+	// A detached socket keeps what it can for its client to collect when it
+	// resumes. A dropped patch is caught up by the render on resume.
+	if detached {
+		if s.compactQueue(msg, true) {
+			return nil
+		}
+		return fmt.Errorf("%w: %s event dropped while detached", ErrOutboundFull, msg.T)
+	}
+
+	policy := s.engine.outbound.Policy
+	if policy == OutboundMergePatches || policy == OutboundDropStale {
+		if s.compactQueue(msg, policy == OutboundDropStale) {
+			return nil
+		}
+		if policy == OutboundDropStale {
+			return fmt.Errorf("%w: %s event dropped", ErrOutboundFull, msg.T)
+		}
+	}
+
+	// A socket which has never had a websocket has no one to disconnect.
+	if closeSlow == nil {
+		return fmt.Errorf("%w: %w", ErrOutboundFull, ErrNotConnected)
+	}
+	go closeSlow()
+	return fmt.Errorf("%w: socket too slow, disconnecting", ErrOutboundFull)
+}
+
+// compactQueue makes room for a message on the full outbound queue by merging
+// consecutive patches, and optionally dropping a stale event. Returns true if
+// the message has been queued. Must be called holding queueMu.
+func (s *Socket) compactQueue(msg Event, dropStale bool) bool {
+	var queued []Event
+drain:
+	for {
+		select {
+		case m := <-s.msgs:
+			queued = append(queued, m)
+		default:
+			break drain
+		}
+	}
+
+	compacted := make([]Event, 0, len(queued))
+	for _, m := range queued {
+		if n := len(compacted); n > 0 {
+			if merged, ok := mergePatches(compacted[n-1], m); ok {
+				compacted[n-1] = merged
+				continue
+			}
+		}
+		compacted = append(compacted, m)
+	}
+	if dropStale && msg.ID == 0 && msg.T != EventPatch {
+		for i, m := range compacted {
+			if m.T == msg.T && m.ID == 0 {
+				compacted = append(compacted[:i], compacted[i+1:]...)
+				break
+			}
+		}
+	}
+
+	queuedMsg := false
+	if n := len(compacted); n > 0 {
+		if merged, ok := mergePatches(compacted[n-1], msg); ok {
+			compacted[n-1] = merged
+			queuedMsg = true
+		}
+	}
+	if !queuedMsg && len(compacted) < cap(s.msgs) {
+		compacted = append(compacted, msg)
+		queuedMsg = true
+	}
+	// There are no more messages than were drained, so this can't block.
+	for _, m := range compacted {
+		s.msgs <- m
+	}
+	return queuedMsg
+}
+
+// mergePatches merges two patch events into one, which applies the patches of
+// both in order.
+func mergePatches(a, b Event) (Event, bool) {
+	if a.T != EventPatch || b.T != EventPatch || a.ID != 0 || b.ID != 0 {
+		return Event{}, false
+	}
+	var first, second []json.RawMessage
+	if err := json.Unmarshal(a.Data, &first); err != nil {
+		return Event{}, false
+	}
+	if err := json.Unmarshal(b.Data, &second); err != nil {
+		return Event{}, false
+	}
+	d, err := json.Marshal(append(first, second...))
+	if err != nil {
+		return Event{}, false
+	}
+	a.Data = d
+	return a, true
+}
@@ -0,0 +1,142 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+func outboundSocket(t *testing.T, q OutboundQueue) *Socket {
+	t.Helper()
+	e := NewHttpHandler(context.Background(), testHandler(), WithOutboundQueue(q))
+	s := NewSocket(context.Background(), e, "")
+	t.Cleanup(s.close)
+	return s
+}
+
+func queuedEvents(s *Socket) []Event {
+	var out []Event
+	for {
+		select {
+		case m := <-s.Messages():
+			out = append(out, m)
+		default:
+			return out
+		}
+	}
+}
+
+func TestOutboundNotUpgraded(t *testing.T) {
+	s := outboundSocket(t, OutboundQueue{Size: 2})
+	for range 2 {
+		if err := s.Send("event", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := s.Send("event", nil)
+	if !errors.Is(err, ErrOutboundFull) || !errors.Is(err, ErrNotConnected) {
+		t.Errorf("expected full queue error, got %v", err)
+	}
+}
+
+func TestOutboundMergePatches(t *testing.T) {
+	s := outboundSocket(t, OutboundQueue{Size: 2, Policy: OutboundMergePatches})
+	for _, anchor := range []string{"_l1", "_l2", "_l3"} {
+		if err := s.Send(EventPatch, []Patch{{Anchor: anchor}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events := queuedEvents(s)
+	if len(events) != 1 {
+		t.Fatalf("expected patches to be merged into one event, got %d", len(events))
+	}
+	var patches []Patch
+	if err := json.Unmarshal(events[0].Data, &patches); err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 3 || patches[0].Anchor != "_l1" || patches[2].Anchor != "_l3" {
+		t.Errorf("expected patches in order, got %v", patches)
+	}
+}
+
+func TestOutboundDropStale(t *testing.T) {
+	s := outboundSocket(t, OutboundQueue{Size: 2, Policy: OutboundDropStale})
+	for i := range 3 {
+		if err := s.Send("price", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Send(EventAck, nil, WithID(1)); !errors.Is(err, ErrOutboundFull) {
+		t.Errorf("expected ack with nothing stale to be dropped, got %v", err)
+	}
+	events := queuedEvents(s)
+	if len(events) != 2 || string(events[0].Data) != "1" || string(events[1].Data) != "2" {
+		t.Errorf("expected oldest price to be dropped, got %v", events)
+	}
+}
+
+func TestOutboundFullEngine(t *testing.T) {
+	for _, policy := range []OutboundPolicy{OutboundDisconnect, OutboundMergePatches, OutboundDropStale} {
+		t.Run(fmt.Sprint(policy), func(t *testing.T) {
+			var flooded atomic.Bool
+			price := strings.Repeat("1", 512*1024)
+			h := testHandler()
+			h.HandleEvent("flood", func(ctx context.Context, s *Socket, p Params) (any, error) {
+				// Send more than the client can take without reading, so
+				// that the websocket write blocks and the queue fills.
+				for range 64 {
+					s.Send("price", price)
+				}
+				flooded.Store(true)
+				return NoRender, nil
+			})
+			_, wsURL := testServer(t, h, WithOutboundQueue(OutboundQueue{Size: 4, WriteTimeout: time.Minute, Policy: policy}))
+			c := testDial(t, wsURL, SocketID(fmt.Sprintf("full-%d", policy)))
+			defer c.Close(websocket.StatusNormalClosure, "")
+			c.SetReadLimit(-1)
+
+			testWrite(t, c, Event{T: "flood", ID: 1})
+			eventually(t, flooded.Load)
+			time.Sleep(20 * time.Millisecond)
+
+			if policy != OutboundDropStale {
+				for {
+					_, _, err := c.Read(context.Background())
+					if err == nil {
+						continue
+					}
+					if websocket.CloseStatus(err) != websocket.StatusPolicyViolation {
+						t.Errorf("expected slow socket to be disconnected, got %v", err)
+					}
+					return
+				}
+			}
+
+			// Dropping stale events keeps the socket connected, even if
+			// the ack had to be dropped.
+			testWrite(t, c, Event{T: "ping", ID: 2})
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			for {
+				_, d, err := c.Read(ctx)
+				if err != nil {
+					t.Fatalf("expected socket to stay connected, got %v", err)
+				}
+				var ev Event
+				if err := json.Unmarshal(d, &ev); err != nil {
+					t.Fatal(err)
+				}
+				if ev.T == EventAck && ev.ID == 2 {
+					return
+				}
+			}
+		})
+	}
+}
@@ -3,8 +3,10 @@ package page
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/jfyne/live"
 )
@@ -20,11 +22,15 @@ type MountHandler func(ctx context.Context, c *Component) error
 type RenderHandler func(w io.Writer, c *Component) error
 
 // EventHandler for a component, only needs the params as the event is scoped to both the socket and then component
-// itself. Returns any component state that needs updating.
+// itself. Returns any component state that needs updating, or live.NoRender to skip rendering.
 type EventHandler func(ctx context.Context, p live.Params) (any, error)
 
+// ReplyHandler for a component, handles an event and returns any component state
+// that needs updating along with a reply to the client.
+type ReplyHandler func(ctx context.Context, p live.Params) (any, any, error)
+
 // SelfHandler for a component, only needs the data as the event is scoped to both the socket and then component
-// itself. Returns any component state that needs updating.
+// itself. Returns any component state that needs updating, or live.NoRender to skip rendering.
 type SelfHandler func(ctx context.Context, data any) (any, error)
 
 // ComponentConstructor a func for creating a new component.
@@ -105,6 +111,18 @@ func (c *Component) Self(ctx context.Context, s *live.Socket, event string, data
 	return s.Self(ctx, c.Event(event), data)
 }
 
+// SendAfter sends an event scoped to this component after the duration. The returned func
+// cancels it.
+func (c *Component) SendAfter(s *live.Socket, d time.Duration, event string, data any) func() {
+	return s.SendAfter(d, c.Event(event), data)
+}
+
+// Interval sends an event scoped to this component every duration. The returned func
+// cancels it.
+func (c *Component) Interval(s *live.Socket, d time.Duration, event string, data any) func() {
+	return s.Interval(d, c.Event(event), data)
+}
+
 // HandleSelf handles scoped incoming events send by a components Self function.
 func (c *Component) HandleSelf(event string, handler SelfHandler) {
 	c.Handler.HandleSelf(c.Event(event), func(ctx context.Context, s *live.Socket, d any) (any, error) {
@@ -112,21 +130,55 @@ func (c *Component) HandleSelf(event string, handler SelfHandler) {
 		if err != nil {
 			return s.Assigns(), err
 		}
+		if state == live.NoRender {
+			return live.NoRender, nil
+		}
 		c.State = state
 		return s.Assigns(), nil
 	})
 }
 
 // HandleEvent handles a component event sent from a connected socket.
-func (c *Component) HandleEvent(event string, handler EventHandler) {
+func (c *Component) HandleEvent(event string, handler EventHandler, options ...live.EventOption) {
 	c.Handler.HandleEvent(c.Event(event), func(ctx context.Context, s *live.Socket, p live.Params) (any, error) {
 		state, err := handler(ctx, p)
 		if err != nil {
 			return s.Assigns(), err
 		}
+		if superseded(ctx) {
+			return s.Assigns(), nil
+		}
+		if state == live.NoRender {
+			return live.NoRender, nil
+		}
 		c.State = state
 		return s.Assigns(), nil
-	})
+	}, options...)
+}
+
+// HandleEventReply handles a component event sent from a connected socket, and
+// replies to the client.
+func (c *Component) HandleEventReply(event string, handler ReplyHandler, options ...live.EventOption) {
+	c.Handler.HandleEventReply(c.Event(event), func(ctx context.Context, s *live.Socket, p live.Params) (any, any, error) {
+		state, reply, err := handler(ctx, p)
+		if err != nil {
+			return s.Assigns(), nil, err
+		}
+		if superseded(ctx) {
+			return s.Assigns(), nil, nil
+		}
+		if state == live.NoRender {
+			return live.NoRender, reply, nil
+		}
+		c.State = state
+		return s.Assigns(), reply, nil
+	}, options...)
+}
+
+// superseded has a newer event replaced the one being handled, in which case
+// its state is discarded.
+func superseded(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), live.ErrEventSuperseded)
 }
 
 // HandleParams handles parameter changes. Caution these handlers are not scoped to a specific component.
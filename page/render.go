@@ -3,6 +3,8 @@ package page
 import (
 	"html/template"
 	"io"
+
+	"github.com/jfyne/live"
 )
 
 // HTML render some html with added template functions to support components. This
@@ -10,6 +12,7 @@ import (
 //
 // Template functions
 // - "Event" takes an event string and scopes it for the component.
+// - "liveClick", "liveValue" and the other live.TemplateFuncs, scoped for the component.
 func HTML(layout string, c *Component) RenderFunc {
 	t := template.Must(template.New("").Funcs(templateFuncs(c)).Parse(layout))
 	return RenderFunc(func(w io.Writer) error {
@@ -21,9 +24,13 @@ func HTML(layout string, c *Component) RenderFunc {
 }
 
 func templateFuncs(c *Component) template.FuncMap {
-	return template.FuncMap{
-		"Event": c.Event,
-	}
+	// Binding helpers are scoped to the component, in the same way as its
+	// event handlers.
+	funcs := live.BindingFuncs(func(event string) (string, error) {
+		return c.Handler.ResolveEvent(c.Event(event))
+	})
+	funcs["Event"] = c.Event
+	return funcs
 }
 
 // RenderFunc a helper function to ease the rendering of nodes.
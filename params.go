@@ -1,10 +1,17 @@
 package live
 
 import (
+	"encoding/json"
+	"fmt"
+	"maps"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
+// connectParam the query parameter the client sends its connect params in.
+const connectParam = "_pconnect"
+
 // Params event params.
 type Params map[string]any
 
@@ -94,16 +101,74 @@ func mapFloat32(p map[string]any, key string) float32 {
 	return 0.0
 }
 
-// NewParamsFromRequest helper to generate Params from an http request.
+// NewParamsFromRequest helper to generate Params from an http request. This
+// includes the query, and the path wildcards if it was routed by a ServeMux
+// pattern such as "GET /projects/{id}".
 func NewParamsFromRequest(r *http.Request) Params {
 	out := Params{}
 	values := r.URL.Query()
 	for k, v := range values {
+		// The socket ID and CSRF token are for live, not the handlers.
+		if k == cookieSocketID || k == csrfParam || k == connectParam {
+			continue
+		}
 		if len(v) == 1 {
 			out[k] = v[0]
 		} else {
 			out[k] = v
 		}
 	}
+	// Path params can't be overridden by the query.
+	maps.Copy(out, pathParamsFromRequest(r))
 	return out
 }
+
+// pathParamsFromRequest gets the values of the wildcards in the ServeMux
+// pattern which matched the request.
+func pathParamsFromRequest(r *http.Request) Params {
+	out := Params{}
+	pattern := r.Pattern
+	// Skip the method and host.
+	if i := strings.Index(pattern, "/"); i >= 0 {
+		pattern = pattern[i:]
+	}
+	for {
+		start := strings.Index(pattern, "{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(pattern[start:], "}")
+		if end < 0 {
+			break
+		}
+		name := strings.TrimSuffix(pattern[start+1:start+end], "...")
+		pattern = pattern[start+end+1:]
+		if name == "$" {
+			continue
+		}
+		out[name] = r.PathValue(name)
+	}
+	return out
+}
+
+// connectParamsFromRequest gets the params the client sent when dialing the
+// websocket.
+func connectParamsFromRequest(r *http.Request) (Params, error) {
+	p := Params{}
+	raw := r.URL.Query().Get(connectParam)
+	if raw == "" {
+		return p, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &p); err != nil {
+		return nil, fmt.Errorf("malformed connect params: %w", err)
+	}
+	return p, nil
+}
+
+// paramsFromRequest generates Params from an http request, without the socket
+// ID under the name configured for the engine.
+func (e *Engine) paramsFromRequest(r *http.Request) Params {
+	p := NewParamsFromRequest(r)
+	delete(p, e.socketCookie.Name)
+	return p
+}
@@ -2,6 +2,7 @@ package live
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 )
@@ -112,3 +113,40 @@ func TestParamsFromRequest(t *testing.T) {
 		t.Error("did not get expected params", params)
 	}
 }
+
+func TestConnectParamsFromRequest(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/?_pconnect=%7B%22locale%22%3A%22en-GB%22%7D", nil)
+	p, err := connectParamsFromRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.String("locale") != "en-GB" {
+		t.Errorf("expected locale, got %v", p)
+	}
+	if params := NewParamsFromRequest(r); len(params) != 0 {
+		t.Errorf("expected connect params to be excluded from request params, got %v", params)
+	}
+
+	r, _ = http.NewRequest("GET", "/?_pconnect=nope", nil)
+	if _, err := connectParamsFromRequest(r); err == nil {
+		t.Error("expected malformed connect params to error")
+	}
+}
+
+func TestPathParams(t *testing.T) {
+	var params, pathParams Params
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /projects/{id}/files/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		params = NewParamsFromRequest(r)
+		pathParams = pathParamsFromRequest(r)
+	})
+	r, _ := http.NewRequest("GET", "/projects/7/files/a/b.txt?id=8&sort=name", nil)
+	mux.ServeHTTP(httptest.NewRecorder(), r)
+
+	if pathParams.String("id") != "7" || pathParams.String("path") != "a/b.txt" || len(pathParams) != 2 {
+		t.Errorf("unexpected path params %v", pathParams)
+	}
+	if params.String("id") != "7" || params.String("sort") != "name" {
+		t.Errorf("expected path params to take precedence over the query, got %v", params)
+	}
+}
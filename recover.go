@@ -0,0 +1,91 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+)
+
+// PanicPolicy decides what happens to a socket after a handler panics.
+type PanicPolicy int
+
+const (
+	// PanicContinue report the panic to the client and keep the socket open.
+	PanicContinue PanicPolicy = iota
+	// PanicClose report the panic to the client and close the socket.
+	PanicClose
+)
+
+// PanicHandler called when a panic is recovered in a mount, unmount, event,
+// self, params or render handler. The socket is nil if the panic happened before one existed.
+type PanicHandler func(ctx context.Context, sock *Socket, err *PanicError)
+
+// PanicError a panic recovered from a handler.
+type PanicError struct {
+	// Value passed to panic.
+	Value any
+	// Stack trace of the goroutine that panicked.
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("handler panic: %v", p.Value)
+}
+
+// WithPanicHandler set the engines panic handler.
+func WithPanicHandler(handler PanicHandler) EngineConfig {
+	return func(e *Engine) error {
+		e.PanicHandler = handler
+		return nil
+	}
+}
+
+// WithPanicPolicy set what happens to a socket after a handler panics.
+func WithPanicPolicy(policy PanicPolicy) EngineConfig {
+	return func(e *Engine) error {
+		e.PanicPolicy = policy
+		return nil
+	}
+}
+
+// defaultPanicHandler logs the panic and its stack trace.
+func defaultPanicHandler(ctx context.Context, sock *Socket, err *PanicError) {
+	var ID SocketID
+	if sock != nil {
+		ID = sock.ID()
+	}
+	slog.Error("recovered handler panic", "socket", ID, "err", err, "stack", string(err.Stack))
+}
+
+// recoverPanic recovers a panic in a handler, reports it and sets it as
+// the error. It must be deferred.
+func (e *Engine) recoverPanic(ctx context.Context, sock *Socket, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	pe := &PanicError{Value: r, Stack: debug.Stack()}
+	e.PanicHandler(ctx, sock, pe)
+	*err = pe
+}
+
+// closeOnPanic checks if an error is a recovered panic which should close
+// the socket.
+func (e *Engine) closeOnPanic(err error) bool {
+	var pe *PanicError
+	return errors.As(err, &pe) && e.PanicPolicy == PanicClose
+}
+
+// callMount runs the mount handler.
+func (e *Engine) callMount(ctx context.Context, sock *Socket) (data any, err error) {
+	defer e.recoverPanic(ctx, sock, &err)
+	return e.socketHandler(sock).MountHandler(ctx, sock)
+}
+
+// callUnmount runs the unmount handler.
+func (e *Engine) callUnmount(ctx context.Context, sock *Socket) (err error) {
+	defer e.recoverPanic(ctx, sock, &err)
+	return e.socketHandler(sock).UnmountHandler(sock)
+}
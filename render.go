@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"log/slog"
+	"time"
 
 	"golang.org/x/net/html"
 )
@@ -15,6 +17,11 @@ type RenderContext struct {
 	Socket  *Socket
 	Uploads UploadContext
 	Assigns any
+	// Flash messages to show, they are cleared once rendered by a
+	// connected socket.
+	Flash Flash
+	// Async the state of the sockets async tasks, by name.
+	Async map[string]AsyncResult
 }
 
 // RenderSocket takes the engine and current socket and renders it to html.
@@ -23,9 +30,11 @@ func RenderSocket(ctx context.Context, e *Engine, s *Socket) (*html.Node, error)
 		Socket:  s,
 		Uploads: s.Uploads(),
 		Assigns: s.Assigns(),
+		Flash:   s.Flash(),
+		Async:   s.Async(),
 	}
 
-	output, err := e.Handler.RenderHandler(ctx, rc)
+	output, err := e.callRender(ctx, rc)
 	if err != nil {
 		return nil, fmt.Errorf("render error: %w", err)
 	}
@@ -40,19 +49,138 @@ func RenderSocket(ctx context.Context, e *Engine, s *Socket) (*html.Node, error)
 		if err != nil {
 			return nil, fmt.Errorf("diff error: %w", err)
 		}
+		// If the patches can't be sent the render isn't kept, so that the
+		// next render catches the client up.
 		if len(patches) != 0 {
-			s.Send(EventPatch, patches)
+			if err := s.Send(EventPatch, patches); err != nil {
+				return nil, fmt.Errorf("send patches error: %w", err)
+			}
 		}
 	} else {
 		anchorTree(render, newAnchorGenerator())
 	}
+	// The flash is only cleared once it is on its way to the client.
+	if s.Connected() && len(rc.Flash) > 0 {
+		s.clearShownFlash(rc.Flash)
+	}
+
+	return render, nil
+}
+
+// renderSocket renders the socket and keeps the render to diff the next one
+// against. Renders of a socket are serialised as they can be triggered by
+// client events, server side events and uploads at the same time.
+func (e *Engine) renderSocket(ctx context.Context, s *Socket) error {
+	s.renderMu.Lock()
+	defer s.renderMu.Unlock()
+	s.mu.Lock()
+	s.renderDirty = false
+	s.lastRender = time.Now()
+	s.mu.Unlock()
+	render, err := RenderSocket(ctx, e, s)
+	if err != nil {
+		return err
+	}
+	s.UpdateRender(render)
+	return nil
+}
+
+// renderLater marks the socket as needing a render, which is flushed at most
+// once every RenderInterval. Without an interval the socket is rendered
+// straight away.
+func (e *Engine) renderLater(ctx context.Context, s *Socket) error {
+	if e.RenderInterval <= 0 {
+		return e.renderSocket(ctx, s)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.renderDirty = true
+	if s.renderTimer != nil {
+		return nil
+	}
+	delay := max(time.Until(s.lastRender.Add(e.RenderInterval)), 0)
+	s.renderTimer = time.AfterFunc(delay, func() {
+		e.flushRender(s)
+	})
+	return nil
+}
+
+// flushRender renders a socket marked as needing a render, unless it has
+// been rendered since. A detached socket is left marked, it is rendered when
+// it is resumed.
+func (e *Engine) flushRender(s *Socket) {
+	s.mu.Lock()
+	if s.renderTimer != nil {
+		s.renderTimer.Stop()
+		s.renderTimer = nil
+	}
+	dirty := s.renderDirty
+	detached := s.detachTimer != nil
+	s.mu.Unlock()
+	if !dirty || detached || s.ctx.Err() != nil {
+		return
+	}
+	if err := e.renderSocket(s.ctx, s); err != nil {
+		slog.Error("socket render error", "socket", s.ID(), "err", err)
+		e.reportPanic(s, Event{}, err)
+	}
+}
 
+// callRender runs the render handler.
+func (e *Engine) callRender(ctx context.Context, rc *RenderContext) (output io.Reader, err error) {
+	defer e.recoverPanic(ctx, rc.Socket, &err)
+	return e.socketHandler(rc.Socket).RenderHandler(ctx, rc)
+}
+
+// setBodyAttr sets an attribute on the body of a render. This is used to give
+// the page sent to the client its socket ID and CSRF token. It is not done on
+// the render that is kept to diff against, as a signed ID differs each time it
+// is issued.
+func setBodyAttr(root *html.Node, key, val string) {
+	if root.Type == html.ElementNode && root.Data == "body" {
+		for idx, a := range root.Attr {
+			if a.Key == key {
+				root.Attr[idx].Val = val
+				return
+			}
+		}
+		root.Attr = append(root.Attr, html.Attribute{Key: key, Val: val})
+		return
+	}
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		setBodyAttr(c, key, val)
+	}
+}
+
+// encodeRender serialises a render so that it can be stored in a SocketState.
+func encodeRender(render *html.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := html.Render(&buf, render); err != nil {
+		return nil, fmt.Errorf("render encode error: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeRender restores a render from a SocketState, anchors included.
+func decodeRender(data []byte) (*html.Node, error) {
+	render, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("render decode error: %w", err)
+	}
+	shapeTree(render)
 	return render, nil
 }
 
 // WithTemplateRenderer set the handler to use an `html/template` renderer.
+// If the template was parsed with TemplateFuncs the events it binds are checked
+// against the handler as it renders.
 func WithTemplateRenderer(t *template.Template) HandlerConfig {
 	return func(h *Handler) error {
+		// Check bound events against the handler, if the template was parsed
+		// with the binding helpers.
+		if checked, err := t.Clone(); err == nil {
+			t = checked.Funcs(BindingFuncs(h.ResolveEvent))
+		}
 		h.RenderHandler = func(ctx context.Context, rc *RenderContext) (io.Reader, error) {
 			var buf bytes.Buffer
 			if err := t.Execute(&buf, rc); err != nil {
@@ -0,0 +1,197 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// EventNavigate sent to navigate to another handler of a Router over the same
+// websocket. Can be sent both directions.
+const EventNavigate = "navigate"
+
+// routeKey context key for the handler a request has been routed to.
+type routeKey struct{}
+
+// routeMatchKey context key for a request which is only matching a route.
+type routeMatchKey struct{}
+
+// routeMatch the result of matching a route.
+type routeMatch struct {
+	handler *Handler
+	req     *http.Request
+}
+
+// navigation the payload of a navigate event.
+type navigation struct {
+	Path string `json:"path"`
+	// Flash signed flash carried to the next handler.
+	Flash string `json:"flash,omitempty"`
+}
+
+// Router serves many handlers from one engine, so that a client can navigate
+// between them over the same websocket with `live-navigate` links or
+// Socket.PushNavigate. Routes use http.ServeMux patterns.
+//
+//	r := live.NewRouter(ctx)
+//	r.Handle("GET /", home)
+//	r.Handle("GET /projects/{id}", project)
+//	http.Handle("/", r)
+type Router struct {
+	engine *Engine
+	mux    *http.ServeMux
+}
+
+// NewRouter creates a router, the engine it serves handlers with is configured
+// with the configs.
+func NewRouter(ctx context.Context, configs ...EngineConfig) *Router {
+	r := &Router{
+		mux: http.NewServeMux(),
+	}
+	r.engine = NewHttpHandler(ctx, NewHandler(), configs...)
+	r.engine.router = r
+	return r
+}
+
+// Engine the engine serving the routes.
+func (r *Router) Engine() *Engine {
+	return r.engine
+}
+
+// Handle serves the handler for requests matching the pattern. A GET pattern
+// also serves POST requests to the same path, which is where the client posts
+// uploads.
+func (r *Router) Handle(pattern string, h *Handler) {
+	serve := func(w http.ResponseWriter, req *http.Request) {
+		if m, ok := req.Context().Value(routeMatchKey{}).(*routeMatch); ok {
+			m.handler = h
+			m.req = req
+			return
+		}
+		r.engine.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), routeKey{}, h)))
+	}
+	r.mux.HandleFunc(pattern, serve)
+	if method, path, ok := strings.Cut(pattern, " "); ok && method == http.MethodGet {
+		r.mux.HandleFunc(http.MethodPost+" "+strings.TrimSpace(path), serve)
+	}
+}
+
+// ServeHTTP serves the handler matching the request.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mux.ServeHTTP(w, req)
+}
+
+// match finds the handler for a URL, along with the request that matched it.
+func (r *Router) match(ctx context.Context, u *url.URL) (*Handler, *http.Request, error) {
+	m := &routeMatch{}
+	req, err := http.NewRequestWithContext(context.WithValue(ctx, routeMatchKey{}, m), http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	r.mux.ServeHTTP(discardResponseWriter{}, req)
+	if m.handler == nil {
+		return nil, nil, fmt.Errorf("no route for %s", u.Path)
+	}
+	return m.handler, m.req, nil
+}
+
+// discardResponseWriter a response writer for matching routes, where
+// nothing is written.
+type discardResponseWriter struct{}
+
+func (discardResponseWriter) Header() http.Header         { return http.Header{} }
+func (discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (discardResponseWriter) WriteHeader(int)             {}
+
+// PushNavigate navigates the client to another handler of the router serving
+// this socket, updating the browser history. The current handler is unmounted
+// and the next one mounted over the same websocket. Without a router, or if no
+// route matches, the client is redirected instead.
+func (s *Socket) PushNavigate(path string) error {
+	return s.Send(EventNavigate, navigation{Path: path, Flash: s.takeFlash()})
+}
+
+// requestHandler the handler a request has been routed to.
+func (e *Engine) requestHandler(ctx context.Context) *Handler {
+	if h, ok := ctx.Value(routeKey{}).(*Handler); ok {
+		return h
+	}
+	return e.Handler
+}
+
+// socketHandler the handler a socket is currently mounted on.
+func (e *Engine) socketHandler(sock *Socket) *Handler {
+	if sock == nil {
+		return e.Handler
+	}
+	if h := sock.liveHandler(); h != nil {
+		return h
+	}
+	return e.Handler
+}
+
+// navigate unmounts the sockets current handler and mounts the handler the
+// path routes to. The websocket request r is checked again, and authenticated
+// as if it had been made to the path, before the handler is mounted.
+func (e *Engine) navigate(ctx context.Context, r *http.Request, sock *Socket, msg Event) error {
+	var nav navigation
+	if err := json.Unmarshal(msg.Data, &nav); err != nil {
+		return ErrMessageMalformed
+	}
+	u, err := url.Parse(nav.Path)
+	if err != nil || u.IsAbs() || u.Host != "" {
+		return fmt.Errorf("%w: navigate path must be relative", ErrMessageMalformed)
+	}
+	if nav.Flash != "" {
+		flash, err := e.verifyFlash(nav.Flash)
+		if err != nil {
+			slog.Warn("navigate", "socket", sock.ID(), "err", err)
+		}
+		sock.mergeFlash(flash)
+	}
+	if e.router == nil {
+		sock.Redirect(u)
+		return nil
+	}
+	h, req, err := e.router.match(ctx, u)
+	if err != nil {
+		slog.Warn("navigate", "socket", sock.ID(), "err", err)
+		sock.Redirect(u)
+		return nil
+	}
+	if err := e.checkRequest(r, sock.ID()); err != nil {
+		return err
+	}
+	authReq := req.Clone(ctx)
+	authReq.Header = r.Header.Clone()
+	authReq.Host = r.Host
+	authReq.RemoteAddr = r.RemoteAddr
+	if err := e.authenticateSocket(ctx, authReq, sock); err != nil {
+		return err
+	}
+
+	if err := e.callUnmount(ctx, sock); err != nil {
+		var pe *PanicError
+		if errors.As(err, &pe) {
+			return fmt.Errorf("socket unmount error: %w", err)
+		}
+		slog.Error("socket unmount error", "err", err)
+	}
+	sock.remount(h)
+	sock.setPathParams(pathParamsFromRequest(req))
+
+	data, err := e.callMount(ctx, sock)
+	if err != nil {
+		return fmt.Errorf("socket mount error: %w", err)
+	}
+	sock.Assign(data)
+	if _, err := e.callParamsHandlers(ctx, sock, NewParamsFromRequest(req)); err != nil {
+		return fmt.Errorf("socket params error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/coder/websocket"
+)
+
+func TestRouter(t *testing.T) {
+	var a, b testCounter
+	r := NewRouter(t.Context())
+	r.Handle("GET /a", a.count(testView(func(*RenderContext) string { return "<p>view a</p>" })))
+	r.Handle("GET /b", b.count(testView(func(*RenderContext) string { return "<p>view b</p>" })))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "view a") {
+		t.Fatalf("expected view a, got %s", body)
+	}
+	resp, err = http.Get(srv.URL + "/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unrouted path, got %d", resp.StatusCode)
+	}
+
+	c := testDial(t, "ws"+strings.TrimPrefix(srv.URL, "http")+"/a", "router")
+	defer c.Close(websocket.StatusNormalClosure, "")
+	eventually(t, func() bool { return a.mounts.Load() == 2 })
+
+	testWrite(t, c, Event{T: EventNavigate, ID: 1, Data: json.RawMessage(`{"path":"/b?page=2"}`)})
+	ev := testRead(t, c)
+	if ev.T != EventPatch {
+		t.Fatalf("expected patch, got %s", ev.T)
+	}
+	if !strings.Contains(string(ev.Data), "view b") {
+		t.Errorf("expected patch to view b, got %s", ev.Data)
+	}
+	if ev := testRead(t, c); ev.T != EventAck {
+		t.Fatalf("expected ack, got %s", ev.T)
+	}
+	if a.unmounts.Load() != 1 || b.mounts.Load() != 1 {
+		t.Errorf("expected a unmounted and b mounted, got %d %d", a.unmounts.Load(), b.mounts.Load())
+	}
+
+	sock, err := r.Engine().GetSocket("router")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sock.PushNavigate("/a"); err != nil {
+		t.Fatal(err)
+	}
+	ev = testRead(t, c)
+	if ev.T != EventNavigate || string(ev.Data) != `{"path":"/a"}` {
+		t.Errorf("expected navigate to /a, got %s %s", ev.T, ev.Data)
+	}
+}
+
+func TestRouterUploadPost(t *testing.T) {
+	r := NewRouter(t.Context())
+	r.Handle("GET /a", testView(func(*RenderContext) string { return "<p>view a</p>" }))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/a", "multipart/form-data", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusMethodNotAllowed {
+		t.Error("expected uploads to be posted to a GET route")
+	}
+}
+
+// adminAuthenticator only lets the admin user see paths under /admin.
+type adminAuthenticator struct{}
+
+func (adminAuthenticator) Authenticate(ctx context.Context, r *http.Request) (any, error) {
+	user := r.Header.Get("X-User")
+	if user == "" || (strings.HasPrefix(r.URL.Path, "/admin") && user != "admin") {
+		return nil, errors.New("not allowed")
+	}
+	return user, nil
+}
+
+func (adminAuthenticator) Revalidate(ctx context.Context, principal any) error {
+	return nil
+}
+
+func TestRouterNavigateAuthenticates(t *testing.T) {
+	var a, admin testCounter
+	r := NewRouter(t.Context(), WithAuthenticator(adminAuthenticator{}))
+	r.Handle("GET /a", a.count(testView(func(*RenderContext) string { return "<p>view a</p>" })))
+	r.Handle("GET /admin", admin.count(testView(func(*RenderContext) string { return "<p>admin</p>" })))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	c, _, err := websocket.Dial(t.Context(), "ws"+strings.TrimPrefix(srv.URL, "http")+"/a", &websocket.DialOptions{
+		HTTPHeader: http.Header{
+			"Cookie": []string{cookieSocketID + "=navauth"},
+			"X-User": []string{"bob"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close(websocket.StatusNormalClosure, "")
+	eventually(t, func() bool { return a.mounts.Load() == 1 })
+
+	testWrite(t, c, Event{T: EventNavigate, ID: 1, Data: json.RawMessage(`{"path":"/admin"}`)})
+	ev := testRead(t, c)
+	for ev.T != EventError {
+		ev = testRead(t, c)
+	}
+	if admin.mounts.Load() != 0 || a.unmounts.Load() != 0 {
+		t.Errorf("expected navigation to be refused, got %d admin mounts", admin.mounts.Load())
+	}
+}
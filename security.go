@@ -0,0 +1,96 @@
+package live
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+const (
+	// csrfParam the query parameter the client sends its CSRF token in.
+	csrfParam = "_pcsrf"
+	// liveCSRF the attribute the CSRF token is rendered into.
+	liveCSRF = "live-csrf"
+)
+
+// WithAllowedOrigins only accept websocket connections and uploads from the
+// same origin as the request host, or an origin host matching one of the given
+// patterns. Patterns follow `path.Match`, for example "*.example.com".
+func WithAllowedOrigins(patterns ...string) EngineConfig {
+	return func(e *Engine) error {
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid origin pattern %q: %w", p, err)
+			}
+		}
+		e.allowedOrigins = append(e.allowedOrigins, patterns...)
+		e.checkOrigins = true
+		return nil
+	}
+}
+
+// WithCSRFProtection render a CSRF token into the page, which the client must
+// present when connecting its websocket or uploading files. The token is derived
+// from the socket ID using the key, so all nodes serving the handler must share
+// the same key.
+func WithCSRFProtection(key []byte) EngineConfig {
+	return func(e *Engine) error {
+		if len(key) < 32 {
+			return fmt.Errorf("csrf key must be at least 32 bytes")
+		}
+		e.csrfKey = key
+		return nil
+	}
+}
+
+// csrfToken generates the CSRF token for a socket.
+func (e *Engine) csrfToken(ID SocketID) string {
+	m := hmac.New(sha256.New, e.csrfKey)
+	m.Write([]byte("csrf:" + string(ID)))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+// checkRequest checks that a websocket upgrade or upload request has come from
+// an allowed origin and has a valid CSRF token for the socket.
+func (e *Engine) checkRequest(r *http.Request, ID SocketID) error {
+	if e.checkOrigins {
+		if err := e.checkOrigin(r); err != nil {
+			return err
+		}
+	}
+	if e.csrfKey != nil {
+		token := r.URL.Query().Get(csrfParam)
+		if token == "" || !hmac.Equal([]byte(token), []byte(e.csrfToken(ID))) {
+			return fmt.Errorf("%w: csrf token invalid", ErrForbidden)
+		}
+	}
+	return nil
+}
+
+// checkOrigin checks the origin header of a request.
+func (e *Engine) checkOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	// Browsers always send an origin for these requests, without one
+	// this isn't a cross site request.
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("%w: origin malformed", ErrForbidden)
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return nil
+	}
+	for _, p := range e.allowedOrigins {
+		if matched, _ := path.Match(strings.ToLower(p), strings.ToLower(u.Host)); matched {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: origin %s not allowed", ErrForbidden, origin)
+}
@@ -0,0 +1,50 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	e := NewHttpHandler(context.Background(), NewHandler(), WithAllowedOrigins("*.example.com"))
+
+	tests := map[string]bool{
+		"":                          true,
+		"http://live.test":          true,
+		"https://app.example.com":   true,
+		"https://example.com":       false,
+		"https://evil.com":          false,
+		"https://app.example.com.x": false,
+	}
+	for origin, allowed := range tests {
+		req := httptest.NewRequest("POST", "http://live.test/", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		err := e.checkRequest(req, "abc")
+		if allowed && err != nil {
+			t.Errorf("%q: expected origin to be allowed, got %s", origin, err)
+		}
+		if !allowed && !errors.Is(err, ErrForbidden) {
+			t.Errorf("%q: expected ErrForbidden, got %v", origin, err)
+		}
+	}
+}
+
+func TestCheckCSRF(t *testing.T) {
+	e := NewHttpHandler(context.Background(), NewHandler(), WithCSRFProtection([]byte("0123456789abcdef0123456789abcdef")))
+
+	req := httptest.NewRequest("POST", "/?"+csrfParam+"="+e.csrfToken("abc"), nil)
+	if err := e.checkRequest(req, "abc"); err != nil {
+		t.Errorf("expected valid token to pass, got %s", err)
+	}
+	if err := e.checkRequest(req, "abd"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("expected token for another socket to fail, got %v", err)
+	}
+	req = httptest.NewRequest("POST", "/", nil)
+	if err := e.checkRequest(req, "abc"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("expected missing token to fail, got %v", err)
+	}
+}
@@ -3,15 +3,19 @@ package live
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"slices"
+	"sync"
 	"time"
 
 	"github.com/coder/websocket"
 	"github.com/rs/xid"
 	"golang.org/x/net/html"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -20,6 +24,9 @@ const (
 
 	// cookieSocketID name for a cookie which holds the current socket ID.
 	cookieSocketID = "_psid"
+	// liveSocketCookie the attribute the name of the socket cookie is
+	// rendered into, when it isn't the default.
+	liveSocketCookie = "live-socket-cookie"
 
 	// infiniteTTL
 	infiniteTTL = 10_000 * (24 * time.Hour)
@@ -35,16 +42,81 @@ type Socket struct {
 	connected     bool
 	currentRender *html.Node
 	msgs          chan Event
+	queueMu       sync.Mutex
 	closeSlow     func()
+	ctx           context.Context
+	cancel        context.CancelCauseFunc
+
+	// stateMu serialises changes to the sockets state in the state store.
+	stateMu sync.Mutex
+	// restored set when the socket has been picked up from the state store,
+	// after being created on another engine or before a restart.
+	restored bool
+
+	// mu guards the connection state below.
+	mu sync.Mutex
+	// conn the websocket currently attached to this socket, nil when the
+	// socket is detached.
+	conn *websocket.Conn
+	// detachTimer expires a detached socket after the grace period.
+	detachTimer *time.Timer
+	// expired set once a detached socket has passed its grace period.
+	expired bool
+	// limiter limits the events this socket can send.
+	limiter *rate.Limiter
+	// unknownEvents the number of events sent without a handler.
+	unknownEvents int
+	// principal who this socket belongs to.
+	principal any
+	// connectParams sent by the client when it dialed the websocket.
+	connectParams Params
+	// calls waiting on a reply from the client, by ID.
+	calls map[int]chan callReply
+	// callStarted signalled when a call starts waiting on a reply.
+	callStarted chan struct{}
+	// writer held by the websocket connection writing the sockets messages,
+	// so that a resumed connection waits for the one it replaced to stop.
+	writer chan struct{}
+	// callID the ID of the last call.
+	callID int
+	// handler the socket is mounted on, when it is served by a Router.
+	handler *Handler
+	// pathParams the wildcards of the route the socket is mounted on.
+	pathParams Params
+	// async tasks started by StartAsync, by name.
+	async map[string]*asyncTask
+	// mountCtx cancelled when the socket is unmounted from its handler, it
+	// bounds the work the handler has started such as async tasks and
+	// timers.
+	mountCtx    context.Context
+	mountCancel context.CancelCauseFunc
+	// lifeCtx the context given out by Context, cancelled when the socket
+	// is unmounted or its client disconnects.
+	lifeCtx    context.Context
+	lifeCancel context.CancelCauseFunc
+	// disconnectCause why the client disconnected, nil while it is
+	// connected.
+	disconnectCause error
 
 	uploadConfigs []*UploadConfig
 	uploads       UploadContext
 
 	selfChan chan socketSelfOp
+
+	// renderMu serialises renders of this socket.
+	renderMu sync.Mutex
+	// renderDirty set when the socket needs a render which has been put off
+	// until renderTimer fires, guarded by mu.
+	renderDirty bool
+	renderTimer *time.Timer
+	// lastRender when the socket was last rendered, guarded by mu.
+	lastRender time.Time
 }
 
 type socketSelfOp struct {
 	Event Event
+	// async the outcome of an async task, rather than a self event.
+	async *asyncDone
 	resp  chan bool
 	err   chan error
 }
@@ -56,7 +128,7 @@ func NewID() string {
 
 // NewSocketFromRequest creates a new default socket from a request.
 func NewSocketFromRequest(ctx context.Context, e *Engine, r *http.Request) (*Socket, error) {
-	sockID, err := socketIDFromReq(r)
+	sockID, err := e.socketIDFromReq(r)
 	if err != nil {
 		return nil, fmt.Errorf("socket id not found: %w", err)
 	}
@@ -66,21 +138,13 @@ func NewSocketFromRequest(ctx context.Context, e *Engine, r *http.Request) (*Soc
 		return existingSock, nil
 	}
 
-	return NewSocket(ctx, e, sockID), nil
-}
-
-func socketIDFromReq(r *http.Request) (SocketID, error) {
-	c, err := r.Cookie(cookieSocketID)
-	if err == nil {
-		return SocketID(c.Value), nil
+	// The socket may have been created on another node, or before a
+	// restart, so pick up where it left off from the state store.
+	sock := NewSocket(ctx, e, sockID)
+	if err := sock.rehydrate(); err != nil {
+		slog.Warn("could not rehydrate socket", "socket", sockID, "err", err)
 	}
-
-	v := r.FormValue(cookieSocketID)
-	if v != "" {
-		return SocketID(v), nil
-	}
-
-	return "", fmt.Errorf("socket id not found in cookie or form data")
+	return sock, nil
 }
 
 // NewSocket creates a new default socket.
@@ -90,25 +154,31 @@ func NewSocket(ctx context.Context, e *Engine, withID SocketID) *Socket {
 		engine:        e,
 		connected:     withID != "",
 		uploadConfigs: []*UploadConfig{},
-		msgs:          make(chan Event, maxMessageBufferSize),
+		msgs:          make(chan Event, e.outboundSize()),
 		selfChan:      make(chan socketSelfOp),
+		callStarted:   make(chan struct{}, 1),
+		writer:        make(chan struct{}, 1),
 	}
 	if withID == "" {
 		s.id = SocketID(NewID())
 	}
-	go s.operate(ctx)
+	if h, ok := ctx.Value(routeKey{}).(*Handler); ok {
+		s.handler = h
+	}
+	// The socket can outlive the request that created it when it is
+	// detached and later resumed, so it manages its own lifetime.
+	s.ctx, s.cancel = context.WithCancelCause(context.WithoutCancel(ctx))
+	s.mountCtx, s.mountCancel = context.WithCancelCause(s.ctx)
+	go s.operate(s.ctx)
 	return s
 }
 
+// WriteFlashCookie writes a short lived cookie containing the socket ID.
 func (s *Socket) WriteFlashCookie(w http.ResponseWriter) {
-	http.SetCookie(w, &http.Cookie{
-		Name:     cookieSocketID,
-		Value:    string(s.id),
-		Path:     "/",
-		HttpOnly: false,
-		SameSite: http.SameSiteStrictMode,
-		MaxAge:   1,
-	})
+	cookie := s.engine.socketCookie
+	cookie.Value = s.engine.encodeSocketID(s.id)
+	cookie.MaxAge = 1
+	http.SetCookie(w, &cookie)
 }
 
 // ID gets the socket ID.
@@ -123,18 +193,159 @@ func (s *Socket) Assigns() any {
 	return state.Data
 }
 
+// NoRender returned as the data from an event, self or params handler leaves
+// the sockets assigns as they are and skips rendering, for events which don't
+// change what is shown such as analytics. Client events are still acknowledged.
+var NoRender = noRender{}
+
+type noRender struct{}
+
 // Assign sets data to this socket. This will happen automatically
 // if you return data from an `EventHander`.
 func (s *Socket) Assign(data any) {
+	if data == NoRender {
+		return
+	}
+	s.updateState(func(state *SocketState) {
+		state.Data = data
+	})
+}
+
+// updateState modify and save this sockets state in the engines state store.
+func (s *Socket) updateState(update func(state *SocketState)) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
 	state, _ := s.engine.socketStateStore.Get(s.id)
-	state.Data = data
+	// The socket has been resumed on another engine, which now owns its
+	// state.
+	if state.Owner != "" && state.Owner != s.engine.id && !s.attached() {
+		slog.Debug("socket state owned by another engine", "socket", s.id)
+		return
+	}
+	update(&state)
 	ttl := 10 * time.Second
 	if s.connected {
 		ttl = infiniteTTL
+		state.Owner = s.engine.id
 	}
 	s.engine.socketStateStore.Set(s.id, state, ttl)
 }
 
+// deleteState removes this sockets state from the engines state store,
+// unless the socket has been resumed on another engine.
+func (s *Socket) deleteState() {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	state, err := s.engine.socketStateStore.Get(s.id)
+	if err == nil && state.Owner != "" && state.Owner != s.engine.id {
+		return
+	}
+	s.engine.socketStateStore.Delete(s.id)
+}
+
+// Principal returns who this socket belongs to, as given by the engines
+// Authenticator. Nil if there is no authenticator.
+func (s *Socket) Principal() any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.principal
+}
+
+// setPrincipal sets who this socket belongs to.
+func (s *Socket) setPrincipal(principal any) {
+	s.mu.Lock()
+	s.principal = principal
+	s.mu.Unlock()
+	s.updateState(func(state *SocketState) {
+		state.Principal = principal
+	})
+}
+
+// ConnectParams returns the params the client sent when it connected its
+// websocket, for example its timezone, locale and viewport. They are set
+// before mount on the connected pass and are nil before that.
+func (s *Socket) ConnectParams() Params {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connectParams
+}
+
+// setConnectParams sets the params sent by the client on connection.
+func (s *Socket) setConnectParams(params Params) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connectParams = params
+}
+
+// liveHandler the handler this socket is mounted on, nil if it is using
+// the engines handler.
+func (s *Socket) liveHandler() *Handler {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.handler
+}
+
+// remount resets the socket ready to be mounted on another handler.
+func (s *Socket) remount(h *Handler) {
+	s.mu.Lock()
+	s.handler = h
+	// Work started by the previous handler is no longer wanted.
+	s.mountCancel(ErrSocketUnmounted)
+	s.mountCtx, s.mountCancel = context.WithCancelCause(s.ctx)
+	s.lifeCtx = nil
+	s.async = nil
+	s.mu.Unlock()
+	s.uploadConfigs = []*UploadConfig{}
+	s.ClearUploads()
+	s.Assign(nil)
+}
+
+// Context returns a context for the lifetime of the socket, which is cancelled
+// when the socket is unmounted or its client disconnects. Use it to bound work
+// started from handlers, such as queries and subscriptions. The reason it was
+// cancelled is given by context.Cause, wrapping ErrSocketUnmounted or
+// ErrSocketDisconnected. The socket ID and principal are available from it
+// with SocketIDFromContext and PrincipalFromContext.
+//
+// If a socket is resumed after its client disconnects a new context is
+// started.
+func (s *Socket) Context() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lifeCtx == nil {
+		ctx := context.WithValue(s.mountCtx, socketIDKey, s.id)
+		ctx = context.WithValue(ctx, principalKey, s.principal)
+		s.lifeCtx, s.lifeCancel = context.WithCancelCause(ctx)
+		if s.disconnectCause != nil {
+			s.lifeCancel(s.disconnectCause)
+		}
+	}
+	return s.lifeCtx
+}
+
+// mountContext the context of the sockets current mount.
+func (s *Socket) mountContext() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mountCtx
+}
+
+// PathParams returns the values of the path wildcards in the ServeMux pattern
+// which routed to this socket, for example "id" for "GET /projects/{id}". They
+// are set before mount on both the GET and websocket connection.
+func (s *Socket) PathParams() Params {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pathParams
+}
+
+// setPathParams sets the path wildcards of the sockets route.
+func (s *Socket) setPathParams(params Params) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pathParams = params
+}
+
 // Connected returns if this socket is connected via the websocket.
 func (s *Socket) Connected() bool {
 	return s.connected
@@ -143,12 +354,21 @@ func (s *Socket) Connected() bool {
 // Self sends an event to this socket itself. Will be handled in the
 // handlers HandleSelf function.
 func (s *Socket) Self(ctx context.Context, event string, data any) error {
-	op := socketSelfOp{
-		Event: Event{T: event, SelfData: data},
-		resp:  make(chan bool),
-		err:   make(chan error),
+	return s.selfOp(ctx, socketSelfOp{Event: Event{T: event, SelfData: data}})
+}
+
+// selfOp hands an operation to the sockets event loop and waits for it to be
+// handled.
+func (s *Socket) selfOp(ctx context.Context, op socketSelfOp) error {
+	op.resp = make(chan bool)
+	op.err = make(chan error)
+	select {
+	case s.selfChan <- op:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.ctx.Done():
+		return ErrNoSocket
 	}
-	s.selfChan <- op
 	select {
 	case <-op.resp:
 		return nil
@@ -161,7 +381,11 @@ func (s *Socket) operate(ctx context.Context) {
 	for {
 		select {
 		case op := <-s.selfChan:
-			s.engine.self(ctx, s, op.Event)
+			if op.async != nil {
+				s.engine.handleAsync(ctx, s, op.async)
+			} else {
+				s.engine.self(ctx, s, op.Event)
+			}
 			op.resp <- true
 		case <-ctx.Done():
 			return
@@ -174,7 +398,9 @@ func (s *Socket) Broadcast(event string, data any) error {
 	return s.engine.Broadcast(event, data)
 }
 
-// Send an event to this socket's client, to be handled there.
+// Send an event to this socket's client, to be handled there. Returns an
+// ErrOutboundFull error if the client can't keep up with the messages sent to
+// it, what happens then depends on the engines OutboundQueue policy.
 func (s *Socket) Send(event string, data any, options ...EventConfig) error {
 	payload, err := json.Marshal(data)
 	if err != nil {
@@ -186,12 +412,7 @@ func (s *Socket) Send(event string, data any, options ...EventConfig) error {
 			return fmt.Errorf("could not configure event: %w", err)
 		}
 	}
-	select {
-	case s.msgs <- msg:
-	default:
-		go s.closeSlow()
-	}
-	return nil
+	return s.enqueue(msg)
 }
 
 // PatchURL sends an event to the client to update the
@@ -203,6 +424,10 @@ func (s *Socket) PatchURL(values url.Values) {
 // Redirect sends a redirect event to the client. This will trigger the browser to
 // redirect to a URL.
 func (s *Socket) Redirect(u *url.URL) {
+	// The flash is kept in a cookie by the client until the next page loads.
+	if flash := s.takeFlash(); flash != "" {
+		s.Send(EventFlash, flash)
+	}
 	s.Send(EventRedirect, u.String())
 }
 
@@ -264,9 +489,44 @@ func (s *Socket) LatestRender() *html.Node {
 	return s.currentRender
 }
 
-// UpdateRender replaces the last render result of this socket.
+// UpdateRender replaces the last render result of this socket. The render is
+// also saved to the state store so that the socket can be resumed elsewhere.
 func (s *Socket) UpdateRender(render *html.Node) {
 	s.currentRender = render
+	if render == nil {
+		return
+	}
+	encoded, err := encodeRender(render)
+	if err != nil {
+		slog.Warn("could not encode render", "socket", s.id, "err", err)
+		return
+	}
+	s.updateState(func(state *SocketState) {
+		state.Render = encoded
+	})
+}
+
+// rehydrate restores the principal and last render of this socket from the
+// state store.
+func (s *Socket) rehydrate() error {
+	state, err := s.engine.socketStateStore.Get(s.id)
+	if err != nil {
+		if errors.Is(err, ErrNoState) {
+			return nil
+		}
+		return err
+	}
+	s.restored = true
+	s.principal = state.Principal
+	if len(state.Render) == 0 {
+		return nil
+	}
+	render, err := decodeRender(state.Render)
+	if err != nil {
+		return err
+	}
+	s.currentRender = render
+	return nil
 }
 
 // Messages returns a channel of event messages sent and received by this socket.
@@ -274,9 +534,129 @@ func (s *Socket) Messages() chan Event {
 	return s.msgs
 }
 
-// assignWS connect a web socket to a socket.
-func (s *Socket) assignWS(ws *websocket.Conn) {
+// attached returns if this socket has a websocket.
+func (s *Socket) attached() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn != nil
+}
+
+// Detached returns if this socket has lost its websocket connection and is
+// waiting to be resumed.
+func (s *Socket) Detached() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.detachTimer != nil
+}
+
+// assignWS connect a web socket to a socket. If the socket was detached it
+// is resumed, if it was attached to another websocket that connection is
+// closed in favour of this one. Returns true if this socket already existed.
+func (s *Socket) assignWS(ws *websocket.Conn) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.expired {
+		return false, ErrSocketExpired
+	}
+	resumed := s.detachTimer != nil || s.conn != nil
+	if s.disconnectCause != nil {
+		s.disconnectCause = nil
+		s.lifeCtx = nil
+	}
+	if s.detachTimer != nil {
+		s.detachTimer.Stop()
+		s.detachTimer = nil
+	}
+	if s.conn != nil && s.conn != ws {
+		go s.conn.Close(websocket.StatusPolicyViolation, "socket attached to a new connection")
+	}
+	s.conn = ws
 	s.closeSlow = func() {
 		ws.Close(websocket.StatusPolicyViolation, "socket too slow to keep up with messages")
 	}
+	return resumed, nil
+}
+
+// detachWS disconnect a web socket from a socket. The socket keeps its state
+// and buffers messages until either it is resumed or the grace period ends,
+// at which point expire is called. The sockets context is cancelled with the
+// reason.
+func (s *Socket) detachWS(ws *websocket.Conn, grace time.Duration, reason error, expire func()) {
+	s.mu.Lock()
+	// This socket has already moved to a newer connection.
+	if s.conn != ws {
+		s.mu.Unlock()
+		return
+	}
+	s.conn = nil
+	s.disconnectCause = ErrSocketDisconnected
+	if reason != nil {
+		s.disconnectCause = fmt.Errorf("%w: %w", ErrSocketDisconnected, reason)
+	}
+	if s.lifeCtx != nil {
+		s.lifeCancel(s.disconnectCause)
+	}
+	if grace <= 0 {
+		s.expired = true
+		s.mu.Unlock()
+		expire()
+		return
+	}
+	expireOnce := func() {
+		s.mu.Lock()
+		if s.expired || s.conn != nil {
+			s.mu.Unlock()
+			return
+		}
+		s.expired = true
+		s.detachTimer = nil
+		s.mu.Unlock()
+		expire()
+	}
+	s.detachTimer = time.AfterFunc(grace, expireOnce)
+	s.mu.Unlock()
+}
+
+// eventLimiter gets the limiter for events sent by this socket, nil if
+// there is no limit.
+func (s *Socket) eventLimiter() *rate.Limiter {
+	limits := s.engine.eventLimits
+	if limits.PerSocket <= 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.limiter == nil {
+		s.limiter = rate.NewLimiter(limits.PerSocket, limits.PerSocketBurst)
+	}
+	return s.limiter
+}
+
+// unknownEvent counts an event sent without a handler, returning the total.
+func (s *Socket) unknownEvent() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unknownEvents++
+	return s.unknownEvents
+}
+
+// disconnect closes the websocket attached to this socket, if there is one.
+func (s *Socket) disconnect(code websocket.StatusCode, reason string) {
+	s.mu.Lock()
+	c := s.conn
+	s.mu.Unlock()
+	if c != nil {
+		c.Close(code, reason)
+	}
+}
+
+// close stops the sockets internal processing.
+func (s *Socket) close() {
+	s.cancel(ErrSocketUnmounted)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.renderTimer != nil {
+		s.renderTimer.Stop()
+		s.renderTimer = nil
+	}
 }
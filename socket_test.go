@@ -0,0 +1,235 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+func TestSocketIDFromRequest(t *testing.T) {
+	e := NewHttpHandler(context.Background(), NewHandler())
+
+	req := httptest.NewRequest("GET", "/?"+cookieSocketID+"=tab", nil)
+	req.AddCookie(&http.Cookie{Name: cookieSocketID, Value: "shared"})
+	ID, err := e.socketIDFromReq(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ID != "tab" {
+		t.Errorf("expected query socket ID to be preferred, got %s", ID)
+	}
+	if _, ok := NewParamsFromRequest(req)[cookieSocketID]; ok {
+		t.Error("socket ID should not be in the params")
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: cookieSocketID, Value: "shared"})
+	ID, err = e.socketIDFromReq(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ID != "shared" {
+		t.Errorf("expected cookie socket ID, got %s", ID)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	if _, err := e.socketIDFromReq(req); err == nil {
+		t.Error("expected error with no socket ID")
+	}
+}
+
+func TestSocketIDFromRequestCustomCookie(t *testing.T) {
+	e := NewHttpHandler(context.Background(), NewHandler(), WithSocketCookie(http.Cookie{Name: "sid"}))
+
+	req := httptest.NewRequest("GET", "/?sid=tab&page=2", nil)
+	ID, err := e.socketIDFromReq(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ID != "tab" {
+		t.Errorf("expected socket ID from the query under the cookie name, got %s", ID)
+	}
+	params := e.paramsFromRequest(req)
+	if _, ok := params["sid"]; ok || params.String("page") != "2" {
+		t.Errorf("expected socket ID not to be in the params, got %v", params)
+	}
+}
+
+func TestSocketIDSigner(t *testing.T) {
+	oldKey := []byte("0123456789abcdef0123456789abcdef")
+	newKey := []byte("fedcba9876543210fedcba9876543210")
+
+	old, err := NewSocketIDSigner(time.Minute, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewSocketIDSigner(time.Minute, newKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ID, err := signer.Verify(signer.Sign("abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ID != "abc" {
+		t.Errorf("expected abc, got %s", ID)
+	}
+
+	// IDs signed with a rotated out key are still valid.
+	if _, err := signer.Verify(old.Sign("abc")); err != nil {
+		t.Errorf("expected ID signed with older key to verify: %s", err)
+	}
+
+	other, err := NewSocketIDSigner(time.Minute, []byte("another key that nobody else uses"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, signed := range map[string]string{
+		"unsigned": "abc",
+		"tampered": "abd" + signer.Sign("abc")[3:],
+		"unknown":  other.Sign("abc"),
+	} {
+		if _, err := signer.Verify(signed); !errors.Is(err, ErrInvalidSocketID) {
+			t.Errorf("%s: expected ErrInvalidSocketID, got %v", name, err)
+		}
+	}
+
+	signed := signer.Sign("abc")
+	signer.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if _, err := signer.Verify(signed); !errors.Is(err, ErrInvalidSocketID) {
+		t.Errorf("expired: expected ErrInvalidSocketID, got %v", err)
+	}
+}
+
+func TestSignedSocketIDExpired(t *testing.T) {
+	signer, err := NewSocketIDSigner(time.Minute, []byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewHttpHandler(context.Background(), NewHandler(), WithSocketIDSigner(signer))
+	signed := signer.Sign("mounted")
+	signer.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+
+	// An expired ID is rejected even once its socket is mounted, so a
+	// leaked ID stops working.
+	sock := NewSocket(context.Background(), e, "mounted")
+	defer sock.close()
+	e.AddSocket(sock)
+	req := httptest.NewRequest("GET", "/?"+cookieSocketID+"="+signed, nil)
+	if _, err := e.socketIDFromReq(req); !errors.Is(err, ErrInvalidSocketID) {
+		t.Fatalf("expected expired ID to be rejected, got %v", err)
+	}
+}
+
+func TestSignedSocketIDRefreshed(t *testing.T) {
+	signer, err := NewSocketIDSigner(2*time.Second, []byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, wsURL := testServer(t, testHandler(), WithSocketIDSigner(signer))
+	c := testDial(t, wsURL, SocketID(signer.Sign("refreshed")))
+	defer c.Close(websocket.StatusNormalClosure, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		_, d, err := c.Read(ctx)
+		if err != nil {
+			t.Fatalf("expected a fresh ID, got %v", err)
+		}
+		var ev Event
+		if err := json.Unmarshal(d, &ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.T != EventSocketID {
+			continue
+		}
+		var signed string
+		if err := json.Unmarshal(ev.Data, &signed); err != nil {
+			t.Fatal(err)
+		}
+		if ID, err := signer.Verify(signed); err != nil || ID != "refreshed" {
+			t.Errorf("expected fresh ID for refreshed, got %s %v", ID, err)
+		}
+		return
+	}
+}
+
+func TestSignedSocketIDRejected(t *testing.T) {
+	signer, err := NewSocketIDSigner(time.Minute, []byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewHttpHandler(context.Background(), NewHandler(), WithSocketIDSigner(signer))
+
+	for _, method := range []string{"POST", "GET"} {
+		req := httptest.NewRequest(method, "/?"+cookieSocketID+"=unsigned", nil)
+		if method == "GET" {
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", "websocket")
+		}
+		rr := httptest.NewRecorder()
+		e.ServeHTTP(rr, req)
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("%s: expected forbidden, got %d", method, rr.Code)
+		}
+	}
+}
+
+func TestSocketContext(t *testing.T) {
+	ctxs := make(chan context.Context, 1)
+	h := testHandler()
+	h.MountHandler = func(_ context.Context, s *Socket) (any, error) {
+		if s.Connected() {
+			ctxs <- s.Context()
+		}
+		return nil, nil
+	}
+	_, wsURL := testServer(t, h, WithAuthenticator(&testAuthenticator{}))
+	c, _, err := websocket.Dial(context.Background(), wsURL, &websocket.DialOptions{
+		HTTPHeader: http.Header{
+			"Cookie": []string{cookieSocketID + "=context"},
+			"X-User": []string{"alice"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev := testRead(t, c); ev.T != EventConnect {
+		t.Fatalf("expected connect event, got %s", ev.T)
+	}
+	ctx := <-ctxs
+
+	if id, ok := SocketIDFromContext(ctx); !ok || id != "context" {
+		t.Errorf("unexpected socket ID %q", id)
+	}
+	if p := PrincipalFromContext(ctx); p != "alice" {
+		t.Errorf("unexpected principal %v", p)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("expected context to be live while connected")
+	}
+
+	c.Close(websocket.StatusNormalClosure, "")
+	eventually(t, func() bool { return ctx.Err() != nil })
+	if !errors.Is(context.Cause(ctx), ErrSocketDisconnected) {
+		t.Errorf("expected disconnected cause, got %v", context.Cause(ctx))
+	}
+}
+
+func TestSocketContextUnmount(t *testing.T) {
+	e := NewHttpHandler(context.Background(), testHandler())
+	s := NewSocket(context.Background(), e, "unmount")
+	ctx := s.Context()
+	e.DeleteSocket(s)
+	if !errors.Is(context.Cause(ctx), ErrSocketUnmounted) {
+		t.Errorf("expected unmounted cause, got %v", context.Cause(ctx))
+	}
+}
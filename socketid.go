@@ -0,0 +1,156 @@
+package live
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithSocketIDSigner sign socket IDs before they are handed to the client, and
+// reject any ID coming back from the client which isn't signed, or which has
+// expired.
+func WithSocketIDSigner(signer *SocketIDSigner) EngineConfig {
+	return func(e *Engine) error {
+		e.socketIDSigner = signer
+		return nil
+	}
+}
+
+// WithSocketCookie set the attributes of the cookie used to give the socket ID
+// to the client. The value and max age of the cookie are set by the engine. The
+// client also sends the ID back under the cookies name in the query of its
+// websocket and uploads.
+func WithSocketCookie(cookie http.Cookie) EngineConfig {
+	return func(e *Engine) error {
+		if cookie.Name == "" {
+			return fmt.Errorf("socket cookie must have a name")
+		}
+		e.socketCookie = cookie
+		return nil
+	}
+}
+
+// defaultSocketCookie the cookie attributes used when none are configured.
+func defaultSocketCookie() http.Cookie {
+	return http.Cookie{
+		Name:     cookieSocketID,
+		Path:     "/",
+		HttpOnly: false,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// SocketIDSigner HMAC signs socket IDs so that they can't be guessed or forged,
+// and stop being accepted once they are older than the TTL. IDs are signed with
+// the first key and verified with any key, so keys can be rotated by adding a new
+// key to the front and removing the oldest once its IDs have expired.
+type SocketIDSigner struct {
+	keys [][]byte
+	ttl  time.Duration
+	now  func() time.Time
+}
+
+// NewSocketIDSigner creates a new signer. Signed IDs are accepted for the ttl.
+// The client receives a freshly signed ID every time its websocket connects,
+// and again every half ttl while it stays connected, so the ttl should be
+// longer than the disconnect grace period for sockets to be resumed.
+func NewSocketIDSigner(ttl time.Duration, keys ...[]byte) (*SocketIDSigner, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("socket id signer needs at least one key")
+	}
+	for _, k := range keys {
+		if len(k) < 32 {
+			return nil, fmt.Errorf("socket id signer keys must be at least 32 bytes")
+		}
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("socket id signer ttl must be positive")
+	}
+	return &SocketIDSigner{
+		keys: keys,
+		ttl:  ttl,
+		now:  time.Now,
+	}, nil
+}
+
+// Sign an ID, the output is in the form "<id>.<issued>.<signature>".
+func (s *SocketIDSigner) Sign(ID SocketID) string {
+	payload := string(ID) + "." + strconv.FormatInt(s.now().Unix(), 10)
+	return payload + "." + s.mac(s.keys[0], payload)
+}
+
+// Verify a signed ID, returning the ID if it is valid and hasn't expired.
+func (s *SocketIDSigner) Verify(signed string) (SocketID, error) {
+	sep := strings.LastIndex(signed, ".")
+	if sep == -1 {
+		return "", fmt.Errorf("%w: not signed", ErrInvalidSocketID)
+	}
+	payload, sig := signed[:sep], signed[sep+1:]
+	ID, issuedStr, ok := strings.Cut(payload, ".")
+	if !ok || ID == "" {
+		return "", fmt.Errorf("%w: malformed", ErrInvalidSocketID)
+	}
+
+	valid := false
+	for _, k := range s.keys {
+		if hmac.Equal([]byte(sig), []byte(s.mac(k, payload))) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return "", fmt.Errorf("%w: bad signature", ErrInvalidSocketID)
+	}
+
+	issued, err := strconv.ParseInt(issuedStr, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("%w: malformed issue time", ErrInvalidSocketID)
+	}
+	if s.now().After(time.Unix(issued, 0).Add(s.ttl)) {
+		return "", fmt.Errorf("%w: expired", ErrInvalidSocketID)
+	}
+	return SocketID(ID), nil
+}
+
+func (s *SocketIDSigner) mac(key []byte, payload string) string {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+// encodeSocketID gets the value of a socket ID to give to the client.
+func (e *Engine) encodeSocketID(ID SocketID) string {
+	if e.socketIDSigner == nil {
+		return string(ID)
+	}
+	return e.socketIDSigner.Sign(ID)
+}
+
+// socketIDFromReq finds the socket ID in a request. The client sends the ID that
+// was rendered into its page as a query parameter, which is preferred over the
+// cookie as the cookie is shared between tabs.
+func (e *Engine) socketIDFromReq(r *http.Request) (SocketID, error) {
+	name := e.socketCookie.Name
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		if c, err := r.Cookie(name); err == nil {
+			value = c.Value
+		}
+	}
+	if value == "" {
+		value = r.FormValue(name)
+	}
+	if value == "" {
+		return "", fmt.Errorf("socket id not found in query, cookie or form data")
+	}
+
+	if e.socketIDSigner == nil {
+		return SocketID(value), nil
+	}
+	return e.socketIDSigner.Verify(value)
+}
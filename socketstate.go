@@ -8,9 +8,23 @@ import (
 
 var ErrNoState = errors.New("no state found for socket ID")
 
+// SocketState the state of a socket held in a SocketStateStore.
 type SocketState struct {
+	// Render the encoded HTML of the sockets latest render, used
+	// to diff against when the socket is resumed.
 	Render []byte
-	Data   any
+	// Data the sockets assigns.
+	Data any
+	// Flash the sockets flash messages.
+	Flash Flash
+	// Principal who the socket belongs to, as given by the engines
+	// Authenticator, so that it is checked wherever the socket is
+	// restored. A store which serialises its state must be able to
+	// encode it, and the Authenticator compare it once decoded.
+	Principal any
+	// Owner the engine serving the socket once it has connected, so that a
+	// socket resumed on another engine isn't deleted by the one it left.
+	Owner string
 }
 
 type SocketStateStore interface {
@@ -0,0 +1,54 @@
+package live
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// SendAfter sends an event to this socket itself after the duration, to be
+// handled by the handlers HandleSelf function. The returned func cancels it.
+// It is cancelled when the socket is unmounted.
+func (s *Socket) SendAfter(d time.Duration, event string, data any) func() {
+	ctx, cancel := context.WithCancel(s.mountContext())
+	go func() {
+		defer cancel()
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+			s.selfTimer(ctx, event, data)
+		case <-ctx.Done():
+		}
+	}()
+	return cancel
+}
+
+// Interval sends an event to this socket itself every duration, to be
+// handled by the handlers HandleSelf function. A tick is skipped if the
+// previous one is still being handled. The returned func cancels it. It is
+// cancelled when the socket is unmounted.
+func (s *Socket) Interval(d time.Duration, event string, data any) func() {
+	ctx, cancel := context.WithCancel(s.mountContext())
+	go func() {
+		defer cancel()
+		t := time.NewTicker(d)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				s.selfTimer(ctx, event, data)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return cancel
+}
+
+// selfTimer sends a timers event to the socket.
+func (s *Socket) selfTimer(ctx context.Context, event string, data any) {
+	if err := s.Self(ctx, event, data); err != nil {
+		slog.Debug("timer event not delivered", "socket", s.id, "event", event, "err", err)
+	}
+}
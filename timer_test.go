@@ -0,0 +1,111 @@
+package live
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSendAfter(t *testing.T) {
+	var ticks atomic.Int32
+	done := make(chan struct{})
+	h := testHandler()
+	h.MountHandler = func(ctx context.Context, s *Socket) (any, error) {
+		if s.Connected() {
+			s.SendAfter(10*time.Millisecond, "tick", nil)
+			cancel := s.SendAfter(10*time.Millisecond, "tick", nil)
+			cancel()
+			// Sent once the cancelled event would have been.
+			s.SendAfter(30*time.Millisecond, "done", nil)
+		}
+		return nil, nil
+	}
+	h.HandleSelf("tick", func(ctx context.Context, s *Socket, d any) (any, error) {
+		ticks.Add(1)
+		return nil, nil
+	})
+	h.HandleSelf("done", func(ctx context.Context, s *Socket, d any) (any, error) {
+		close(done)
+		return nil, nil
+	})
+	testConnect(t, h, "timer")
+
+	eventually(t, func() bool {
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	})
+	if n := ticks.Load(); n != 1 {
+		t.Errorf("expected cancelled event not to be sent, got %d ticks", n)
+	}
+}
+
+func TestIntervalCancel(t *testing.T) {
+	var ticks atomic.Int32
+	var cancel func()
+	done := make(chan struct{})
+	h := testHandler()
+	h.MountHandler = func(ctx context.Context, s *Socket) (any, error) {
+		if s.Connected() {
+			cancel = s.Interval(5*time.Millisecond, "tick", nil)
+		}
+		return nil, nil
+	}
+	h.HandleSelf("tick", func(ctx context.Context, s *Socket, d any) (any, error) {
+		ticks.Add(1)
+		return nil, nil
+	})
+	h.HandleSelf("done", func(ctx context.Context, s *Socket, d any) (any, error) {
+		close(done)
+		return nil, nil
+	})
+	_, sock, _ := testConnect(t, h, "timer")
+
+	eventually(t, func() bool { return ticks.Load() >= 3 })
+	cancel()
+	n := ticks.Load()
+	// Sent after several more ticks would have been.
+	sock.SendAfter(50*time.Millisecond, "done", nil)
+	eventually(t, func() bool {
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	})
+	if ticks.Load() > n+1 {
+		t.Errorf("expected interval to stop, went from %d to %d ticks", n, ticks.Load())
+	}
+}
+
+func TestIntervalUnmount(t *testing.T) {
+	var ticks atomic.Int32
+	h := testHandler()
+	h.MountHandler = func(ctx context.Context, s *Socket) (any, error) {
+		if s.Connected() {
+			s.Interval(5*time.Millisecond, "tick", nil)
+		}
+		return nil, nil
+	}
+	h.HandleSelf("tick", func(ctx context.Context, s *Socket, d any) (any, error) {
+		ticks.Add(1)
+		return nil, nil
+	})
+	e, sock, _ := testConnect(t, h, "timer")
+
+	eventually(t, func() bool { return ticks.Load() >= 1 })
+	e.DeleteSocket(sock)
+	// The interval is cancelled with the socket, which can no longer be
+	// sent its events.
+	if sock.Context().Err() == nil {
+		t.Error("expected the sockets context to be cancelled")
+	}
+	if err := sock.Self(context.Background(), "tick", nil); err == nil {
+		t.Error("expected ticks not to be delivered once unmounted")
+	}
+}
@@ -1,2 +1,2 @@
-(()=>{var _=Object.defineProperty;var x=Object.getOwnPropertySymbols;var j=Object.prototype.hasOwnProperty,J=Object.prototype.propertyIsEnumerable;var M=(i,t,e)=>t in i?_(i,t,{enumerable:!0,configurable:!0,writable:!0,value:e}):i[t]=e,h=(i,t)=>{for(var e in t||(t={}))j.call(t,e)&&M(i,e,t[e]);if(x)for(var e of x(t))J.call(t,e)&&M(i,e,t[e]);return i};var y=class{static hook(t){return t.getAttribute===void 0?null:t.getAttribute("live-hook")}};var X="live:mounted",Q="live:beforeupdate",V="live:updated",Y="live:beforedestroy",Z="live:destroyed",tt="live:disconnected",et="live:reconnected",T="live-connected",A="live-disconnected",st="live-error",k=class{constructor(t,e,s){this.typ=t,this.data=e,s!==void 0?this.id=s:this.id=0}static GetID(){return this.sequence++}serialize(){return JSON.stringify({t:this.typ,i:this.id,d:this.data})}static fromMessage(t){let e=JSON.parse(t);return new k(e.t,e.d,e.i)}},o=k;o.sequence=1;var a=class{constructor(){}static init(t,e){this.hooks=t,this.dom=e,this.eventHandlers={}}static handleEvent(t){t.typ in this.eventHandlers&&this.eventHandlers[t.typ].map(e=>{e(t.data)})}static mounted(t){let e=new CustomEvent(X,{}),s=this.getElementHooks(t);s!==null&&this.callHook(e,t,s.mounted)}static beforeUpdate(t,e){let s=new CustomEvent(Q,{}),n=this.getElementHooks(t);n!==null&&this.callHook(s,t,n.beforeUpdate),this.dom!==void 0&&this.dom.onBeforeElUpdated!==void 0&&this.dom.onBeforeElUpdated(t,e)}static updated(t){let e=new CustomEvent(V,{}),s=this.getElementHooks(t);s!==null&&this.callHook(e,t,s.updated)}static beforeDestroy(t){let e=new CustomEvent(Y,{}),s=this.getElementHooks(t);s!==null&&this.callHook(e,t,s.beforeDestroy)}static destroyed(t){let e=new CustomEvent(Z,{}),s=this.getElementHooks(t);s!==null&&this.callHook(e,t,s.destroyed)}static disconnected(){let t=new CustomEvent(tt,{});document.querySelectorAll("[live-hook]").forEach(e=>{let s=this.getElementHooks(e);s!==null&&this.callHook(t,e,s.disconnected)}),document.body.classList.add(A),document.body.classList.remove(T)}static reconnected(){let t=new CustomEvent(et,{});document.querySelectorAll("[live-hook]").forEach(e=>{let s=this.getElementHooks(e);s!==null&&this.callHook(t,e,s.reconnected)}),document.body.classList.remove(A),document.body.classList.add(T)}static error(){document.body.classList.add(st)}static getElementHooks(t){let e=y.hook(t);return e===null?e:this.hooks[e]}static callHook(t,e,s){if(s===void 0)return;let n=d=>{c.send(d)},r=(d,m)=>{d in this.eventHandlers||(this.eventHandlers[d]=[]),this.eventHandlers[d].push(m)};s.bind({el:e,pushEvent:n,handleEvent:r})(),e.dispatchEvent(t)}};var l=class{static dehydrate(){document.querySelectorAll("form").forEach(e=>{if(e.id===""){console.error("form does not have an ID. DOM updates may be affected",e);return}this.formState[e.id]=[],new FormData(e).forEach((s,n)=>{let r={name:n,value:s,focus:e.querySelector(`[name="${n}"]`)==document.activeElement};this.formState[e.id].push(r)})})}static hydrate(){Object.keys(this.formState).map(t=>{let e=document.querySelector(`#${t}`);if(e===null){delete this.formState[t];return}this.formState[t].map(n=>{let r=e.querySelector(`[name="${n.name}"]`);if(r!==null)switch(r.type){case"file":break;case"checkbox":n.value==="on"&&(r.checked=!0);break;default:r.value=n.value,n.focus===!0&&r.focus();break}})})}static serialize(t){let e={};return new FormData(t).forEach((n,r)=>{switch(!0){case n instanceof File:let d=n,m={name:d.name,type:d.type,size:d.size,lastModified:d.lastModified};Reflect.has(e,this.upKey)||(e[this.upKey]={}),Reflect.has(e[this.upKey],r)||(e[this.upKey][r]=[]),e[this.upKey][r].push(m);break;default:if(!Reflect.has(e,r)){e[r]=n;return}Array.isArray(e[r])||(e[r]=[e[r]]),e[r].push(n)}}),e}static hasFiles(t){let e=new FormData(t),s=!1;return e.forEach(n=>{n instanceof File&&(s=!0)}),s}};l.upKey="uploads",l.formState={};var p=class{static handle(t){l.dehydrate(),t.data.map(p.applyPatch),l.hydrate()}static applyPatch(t){let e=document.querySelector(`*[${t.Anchor}]`);if(e===null)return;let s=p.html2Node(t.HTML);switch(t.Action){case 0:return;case 1:t.HTML===""?a.beforeDestroy(e):a.beforeUpdate(e,s),e.outerHTML=t.HTML,t.HTML===""?a.destroyed(e):a.updated(e);break;case 2:a.beforeUpdate(e,s),e.append(s),a.updated(e);break;case 3:a.beforeUpdate(e,s),e.prepend(s),a.updated(e);break}}static html2Node(t){let e=document.createElement("template");return t=t.trim(),e.innerHTML=t,e.content.firstChild===null?document.createTextNode(t):e.content.firstChild}};function E(i){let t={};if(new URLSearchParams(window.location.search).forEach((n,r)=>{t[r]=n}),i===void 0||!i.hasAttributes())return t;let s=i.attributes;for(let n=0;n<s.length;n++)!s[n].name.startsWith("live-value-")||(t[s[n].name.split("live-value-")[1]]=s[n].value);return t}function w(i){let t=new URL(i,location.origin),e=new URLSearchParams(t.search),s={};return e.forEach((n,r)=>{s[r]=n}),s}function b(i,t){if(window.history.pushState({},"",i),t===void 0)c.send(new o("params",h({},w(i))));else{let e=E(t);c.sendAndTrack(new o("params",h(h({},e),w(i)),o.GetID()),t)}}var u=class{constructor(t,e){this.event=t;this.attribute=e;this.limiter=new L}isWired(t){return t.hasAttribute(`${this.attribute}-wired`)?!0:(t.setAttribute(`${this.attribute}-wired`,""),!1)}attach(){document.querySelectorAll(`*[${this.attribute}]`).forEach(t=>{if(this.isWired(t)==!0)return;let e=E(t);t.addEventListener(this.event,s=>{this.limiter.hasDebounce(t)?this.limiter.debounce(t,s,this.handler(t,e)):this.handler(t,e)(s)}),t.addEventListener("ack",s=>{t.classList.remove(`${this.attribute}-loading`)})})}windowAttach(){document.querySelectorAll(`*[${this.attribute}]`).forEach(t=>{if(this.isWired(t)===!0)return;let e=E(t);window.addEventListener(this.event,this.handler(t,e)),window.addEventListener("ack",s=>{t.classList.remove(`${this.attribute}-loading`)})})}handler(t,e){return s=>{let n=t==null?void 0:t.getAttribute(this.attribute);n!==null&&(t.classList.add(`${this.attribute}-loading`),c.sendAndTrack(new o(n,e,o.GetID()),t))}}},f=class extends u{handler(t,e){return s=>{let n=s,r=t==null?void 0:t.getAttribute(this.attribute);if(r===null)return;let d=t.getAttribute("live-key");if(d!==null&&n.key!==d)return;t.classList.add(`${this.attribute}-loading`);let m={key:n.key,altKey:n.altKey,ctrlKey:n.ctrlKey,shiftKey:n.shiftKey,metaKey:n.metaKey};c.sendAndTrack(new o(r,h(h({},e),m),o.GetID()),t)}}},L=class{constructor(){this.debounceAttr="live-debounce"}hasDebounce(t){return t.hasAttribute(this.debounceAttr)}debounce(t,e,s){if(clearTimeout(this.debounceEvent),!this.hasDebounce(t)){s(e);return}let n=t.getAttribute(this.debounceAttr);if(n===null){s(e);return}if(n==="blur"){this.debounceEvent=s,t.addEventListener("blur",()=>{this.debounceEvent()});return}this.debounceEvent=setTimeout(()=>{s(e)},parseInt(n))}},D=class extends u{constructor(){super("click","live-click")}},S=class extends u{constructor(){super("contextmenu","live-contextmenu")}},$=class extends u{constructor(){super("mousedown","live-mousedown")}},P=class extends u{constructor(){super("mouseup","live-mouseup")}},F=class extends u{constructor(){super("focus","live-focus")}},K=class extends u{constructor(){super("blur","live-blur")}},C=class extends u{constructor(){super("focus","live-window-focus")}attach(){this.windowAttach()}},U=class extends u{constructor(){super("blur","live-window-blur")}attach(){this.windowAttach()}},q=class extends f{constructor(){super("keydown","live-keydown")}},W=class extends f{constructor(){super("keyup","live-keyup")}},I=class extends f{constructor(){super("keydown","live-window-keydown")}attach(){this.windowAttach()}},R=class extends f{constructor(){super("keyup","live-window-keyup")}attach(){this.windowAttach()}},N=class{constructor(){this.attribute="live-change";this.limiter=new L}isWired(t){return t.hasAttribute(`${this.attribute}-wired`)?!0:(t.setAttribute(`${this.attribute}-wired`,""),!1)}attach(){let t=[];document.querySelectorAll(`form[${this.attribute}]`).forEach(e=>{e.addEventListener("ack",s=>{e.classList.remove(`${this.attribute}-loading`)}),t.push(e),e.querySelectorAll("input,select,textarea").forEach(s=>{this.addEvent(e,s)})}),t.forEach(e=>{document.querySelectorAll(`[form=${e.getAttribute("id")}]`).forEach(s=>{this.addEvent(e,s)})})}addEvent(t,e){this.isWired(e)||e.addEventListener("input",s=>{this.limiter.hasDebounce(e)?this.limiter.debounce(e,s,()=>{this.handler(t)}):this.handler(t)})}handler(t){let e=t==null?void 0:t.getAttribute(this.attribute);if(e===null)return;let s=l.serialize(t);t.classList.add(`${this.attribute}-loading`),c.sendAndTrack(new o(e,s,o.GetID()),t)}},O=class extends u{constructor(){super("submit","live-submit")}handler(t,e){return s=>{if(s.preventDefault&&s.preventDefault(),l.hasFiles(t)===!0){let r=new XMLHttpRequest;r.open("POST",""),r.addEventListener("load",()=>{this.sendEvent(t,e)}),r.send(new FormData(t))}else this.sendEvent(t,e);return!1}}sendEvent(t,e){let s=t==null?void 0:t.getAttribute(this.attribute);if(s===null)return;var n=h({},e);let r=l.serialize(t);Object.keys(r).map(d=>{n[d]=r[d]}),t.classList.add(`${this.attribute}-loading`),c.sendAndTrack(new o(s,n,o.GetID()),t)}},B=class extends u{constructor(){super("","live-hook")}attach(){document.querySelectorAll(`[${this.attribute}]`).forEach(t=>{this.isWired(t)!=!0&&a.mounted(t)})}},G=class extends u{constructor(){super("click","live-patch")}handler(t,e){return s=>{s.preventDefault&&s.preventDefault();let n=t.getAttribute("href");if(n!==null)return b(n,t),!1}}},v=class{static init(){this.clicks=new D,this.contextmenu=new S,this.mousedown=new $,this.mouseup=new P,this.focus=new F,this.blur=new K,this.windowFocus=new C,this.windowBlur=new U,this.keydown=new q,this.keyup=new W,this.windowKeydown=new I,this.windowKeyup=new R,this.change=new N,this.submit=new O,this.hook=new B,this.patch=new G,this.handleBrowserNav()}static rewire(){this.clicks.attach(),this.contextmenu.attach(),this.mousedown.attach(),this.mouseup.attach(),this.focus.attach(),this.blur.attach(),this.windowFocus.attach(),this.windowBlur.attach(),this.keydown.attach(),this.keyup.attach(),this.windowKeyup.attach(),this.windowKeydown.attach(),this.change.attach(),this.submit.attach(),this.hook.attach(),this.patch.attach()}static handleBrowserNav(){window.onpopstate=function(t){c.send(new o("params",w(document.location.search),o.GetID()))}}};var z="_psid",g=class{constructor(){}static getID(){if(this.id)return this.id;let e=`; ${document.cookie}`.split(`; ${z}=`);if(e&&e.length===2){let s=e.pop();return s?s.split(";").shift():""}return""}static setCookie(){var t=new Date;t.setTime(t.getTime()+60*1e3),document.cookie=`${z}=${this.id}; expires=${t.toUTCString()}; path=/`}static dial(){this.trackedEvents={},this.id=this.getID(),this.setCookie(),console.debug("Socket.dial called",this.id),this.conn=new WebSocket(`${location.protocol==="https:"?"wss":"ws"}://${location.host}${location.pathname}${location.search}${location.hash}`),this.conn.addEventListener("close",t=>{this.ready=!1,console.warn(`WebSocket Disconnected code: ${t.code}, reason: ${t.reason}`),t.code!==1001&&(this.disconnectNotified===!1&&(a.disconnected(),this.disconnectNotified=!0),setTimeout(()=>{g.dial()},1e3))}),this.conn.addEventListener("open",t=>{a.reconnected(),this.disconnectNotified=!1,this.ready=!0}),this.conn.addEventListener("message",t=>{if(typeof t.data!="string"){console.error("unexpected message type",typeof t.data);return}let e=o.fromMessage(t.data);switch(e.typ){case"patch":p.handle(e),v.rewire();break;case"params":b(`${window.location.pathname}?${e.data}`);break;case"redirect":window.location.replace(e.data);break;case"ack":this.ack(e);break;case"err":a.error();default:a.handleEvent(e)}})}static sendAndTrack(t,e){if(this.ready===!1){console.warn("connection not ready for send of event",t);return}this.trackedEvents[t.id]={ev:t,el:e},this.conn.send(t.serialize())}static send(t){if(this.ready===!1){console.warn("connection not ready for send of event",t);return}this.conn.send(t.serialize())}static ack(t){t.id in this.trackedEvents&&(this.trackedEvents[t.id].el.dispatchEvent(new Event("ack")),delete this.trackedEvents[t.id])}},c=g;c.ready=!1,c.disconnectNotified=!1;var H=class{constructor(t,e){this.hooks=t;this.dom=e}init(){document.querySelector("[live-rendered]")!==null&&(a.init(this.hooks,this.dom),c.dial(),v.init(),v.rewire())}send(t,e,s){let n=new o(t,e,s);c.send(n)}};document.addEventListener("DOMContentLoaded",i=>{window.Live!==void 0&&console.error("window.Live already defined");let t=window.Hooks||{};window.Live=new H(t),window.Live.init()});})();
+"use strict";(()=>{var b=class{static hook(t){return t.getAttribute===void 0?null:t.getAttribute("live-hook")}};var J="live:mounted",X="live:beforeupdate",Z="live:updated",Q="live:beforedestroy",V="live:destroyed",Y="live:disconnected",_="live:reconnected",G="live-connected",j="live-disconnected",tt="live-error",r=class i{static{this.sequence=1}constructor(t,e,s){this.typ=t,this.data=e,s!==void 0?this.id=s:this.id=0}static GetID(){return this.sequence++}serialize(){return JSON.stringify({t:this.typ,i:this.id,d:this.data})}static fromMessage(t){let e=JSON.parse(t);return new i(e.t,e.d,e.i)}},l=class{constructor(){}static init(t,e){this.hooks=t,this.dom=e,this.eventHandlers={},this.callHandlers=new WeakMap}static handleEvent(t){t.typ in this.eventHandlers&&this.eventHandlers[t.typ].map(e=>{e(t.data)})}static async handleCall(t){let{hook:e,event:s,payload:n}=t.data,a;try{let o=document.getElementById(e),d=o===null?void 0:this.callHandlers.get(o)?.[s];if(d===void 0)throw new Error(`no call handler for ${s} on ${e}`);a={reply:await d(n)}}catch(o){a={err:o instanceof Error?o.message:String(o)}}c.send(new r("reply",a,t.id))}static mounted(t){let e=new CustomEvent(J,{}),s=this.getElementHooks(t);s!==null&&this.callHook(e,t,s.mounted)}static beforeUpdate(t,e){let s=new CustomEvent(X,{}),n=this.getElementHooks(t);n!==null&&this.callHook(s,t,n.beforeUpdate),this.dom!==void 0&&this.dom.onBeforeElUpdated!==void 0&&this.dom.onBeforeElUpdated(t,e)}static updated(t){let e=new CustomEvent(Z,{}),s=this.getElementHooks(t);s!==null&&this.callHook(e,t,s.updated)}static beforeDestroy(t){let e=new CustomEvent(Q,{}),s=this.getElementHooks(t);s!==null&&this.callHook(e,t,s.beforeDestroy)}static destroyed(t){let e=new CustomEvent(V,{}),s=this.getElementHooks(t);s!==null&&this.callHook(e,t,s.destroyed)}static disconnected(){let t=new CustomEvent(Y,{});document.querySelectorAll("[live-hook]").forEach(e=>{let s=this.getElementHooks(e);s!==null&&this.callHook(t,e,s.disconnected)}),document.body.classList.add(j),document.body.classList.remove(G)}static reconnected(){let t=new CustomEvent(_,{});document.querySelectorAll("[live-hook]").forEach(e=>{let s=this.getElementHooks(e);s!==null&&this.callHook(t,e,s.reconnected)}),document.body.classList.remove(j),document.body.classList.add(G)}static error(){document.body.classList.add(tt)}static getElementHooks(t){let e=b.hook(t);return e===null?e:this.hooks[e]}static callHook(t,e,s){if(s===void 0)return;let n=d=>{let v=d instanceof r?d:new r(d.t,d.d);return c.sendAndReply(v)},a=(d,v)=>{d in this.eventHandlers||(this.eventHandlers[d]=[]),this.eventHandlers[d].push(v)},o=(d,v)=>{let W=this.callHandlers.get(e)||{};W[d]=v,this.callHandlers.set(e,W)};s.bind({el:e,pushEvent:n,handleEvent:a,handleCall:o})(),e.dispatchEvent(t)}};var p=class{static{this.upKey="uploads"}static{this.formState={}}static dehydrate(){document.querySelectorAll("form").forEach(e=>{if(e.id===""){console.error("form does not have an ID. DOM updates may be affected",e);return}this.formState[e.id]=[],new FormData(e).forEach((s,n)=>{let a={name:n,value:s,focus:e.querySelector(`[name="${n}"]`)==document.activeElement};this.formState[e.id].push(a)})})}static hydrate(){Object.keys(this.formState).map(t=>{let e=document.querySelector(`#${t}`);if(e===null){delete this.formState[t];return}this.formState[t].map(n=>{let a=e.querySelector(`[name="${n.name}"]`);if(a!==null)switch(a.type){case"file":break;case"checkbox":n.value==="on"&&(a.checked=!0);break;default:a.value=n.value,n.focus===!0&&a.focus();break}})})}static serialize(t){let e={};return new FormData(t).forEach((n,a)=>{switch(!0){case n instanceof File:let o=n,d={name:o.name,type:o.type,size:o.size,lastModified:o.lastModified};Reflect.has(e,this.upKey)||(e[this.upKey]={}),Reflect.has(e[this.upKey],a)||(e[this.upKey][a]=[]),e[this.upKey][a].push(d);break;default:if(!Reflect.has(e,a)){e[a]=n;return}Array.isArray(e[a])||(e[a]=[e[a]]),e[a].push(n)}}),e}static hasFiles(t){let e=new FormData(t),s=!1;return e.forEach(n=>{n instanceof File&&(s=!0)}),s}};var h=class i{static{this.state={}}static isCommands(t){return t.trimStart().startsWith("[")}static exec(t,e,s={}){(typeof t=="string"?JSON.parse(t):t).forEach(a=>{if(a.op==="push"){i.push(a,e,s);return}i.targets(a,e).forEach(o=>i.apply(a,o))})}static restore(){for(let t in this.state){let e=i.find(t);if(e===null){delete this.state[t];continue}let s=this.state[t];e!==s.el&&(s.el=e,i.restoreElement(e,s),s.display===void 0&&Object.keys(s.classes).length===0&&Object.keys(s.attrs).length===0&&delete this.state[t])}}static reset(){this.state={}}static targets(t,e){return t.to===void 0||t.to===""?e===null?[]:[e]:Array.from(document.querySelectorAll(t.to))}static apply(t,e){switch(t.op){case"show":i.setDisplay(e,"");break;case"hide":i.setDisplay(e,"none");break;case"toggle":i.setDisplay(e,getComputedStyle(e).display==="none"?"":"none");break;case"add_class":(t.classes||[]).forEach(s=>i.setClass(e,s,!0));break;case"remove_class":(t.classes||[]).forEach(s=>i.setClass(e,s,!1));break;case"set_attr":i.setAttr(e,t.attr||"",t.value);break;case"remove_attr":i.setAttr(e,t.attr||"",null);break;case"focus":e.focus();break;case"dispatch":e.dispatchEvent(new CustomEvent(t.event||"",{bubbles:!0,detail:t.value}));break;default:console.warn("unknown js command",t.op)}}static push(t,e,s){let n=new r(t.event||"",{...s,...t.value||{}},r.GetID());if(e===null){c.send(n);return}c.sendAndTrack(n,e)}static setDisplay(t,e){let s=i.elementState(t);s!==null&&(s.display={base:s.display?.base??t.style.display,value:e}),t.style.display=e}static setClass(t,e,s){let n=i.elementState(t);n!==null&&(n.classes[e]={base:n.classes[e]?.base??t.classList.contains(e),value:s}),t.classList.toggle(e,s)}static setAttr(t,e,s){let n=i.elementState(t);n!==null&&(n.attrs[e]={base:e in n.attrs?n.attrs[e].base:t.getAttribute(e),value:s}),i.writeAttr(t,e,s)}static writeAttr(t,e,s){s===null?t.removeAttribute(e):t.setAttribute(e,s)}static restoreElement(t,e){e.display!==void 0&&!i.reconcile(e.display,t.style.display,s=>{t.style.display=s})&&delete e.display;for(let s in e.classes){let n=t.classList.contains(s);i.reconcile(e.classes[s],n,o=>{t.classList.toggle(s,o)})||delete e.classes[s]}for(let s in e.attrs){let n=t.getAttribute(s);i.reconcile(e.attrs[s],n,o=>{i.writeAttr(t,s,o)})||delete e.attrs[s]}}static reconcile(t,e,s){return e!==t.base?!1:(s(t.value),!0)}static elementState(t){let e=i.key(t);return e===null?null:(e in this.state||(this.state[e]={el:t,classes:{},attrs:{}}),this.state[e].el=t,this.state[e])}static key(t){if(t.id!=="")return`#${CSS.escape(t.id)}`;for(let e of Array.from(t.attributes))if(e.name.startsWith("_l"))return`[${e.name}]`;return null}static find(t){return document.querySelector(t)}};var w=class i{static handle(t){p.dehydrate(),t.data.map(i.applyPatch),h.restore(),p.hydrate()}static applyPatch(t){let e=document.querySelector(`*[${t.Anchor}]`);if(e===null)return;let s=i.html2Node(t.HTML);switch(t.Action){case 0:return;case 1:t.HTML===""?l.beforeDestroy(e):l.beforeUpdate(e,s),e.outerHTML=t.HTML,t.HTML===""?l.destroyed(e):l.updated(e);break;case 2:l.beforeUpdate(e,s),e.append(s),l.updated(e);break;case 3:l.beforeUpdate(e,s),e.prepend(s),l.updated(e);break}}static html2Node(t){let e=document.createElement("template");return t=t.trim(),e.innerHTML=t,e.content.firstChild===null?document.createTextNode(t):e.content.firstChild}};function g(i){let t={};if(new URLSearchParams(window.location.search).forEach((n,a)=>{t[a]=n}),i===void 0||!i.hasAttributes())return t;let s=i.attributes;for(let n=0;n<s.length;n++)s[n].name.startsWith("live-value-")&&(t[s[n].name.split("live-value-")[1]]=s[n].value);return t}function y(i){let t=new URL(i,location.origin),e=new URLSearchParams(t.search),s={};return e.forEach((n,a)=>{s[a]=n}),s}function k(i,t){if(window.history.pushState({},"",i),t===void 0)c.send(new r("params",{...y(i)}));else{let e=g(t);c.sendAndTrack(new r("params",{...e,...y(i)},r.GetID()),t)}}var z=location.pathname;function B(){return location.pathname!==z}function E(i,t=!0,e){if(!c.isReady()){e&&(document.cookie=`_pflash=${e}; path=/; max-age=60; samesite=lax`),window.location.assign(i);return}t&&window.history.pushState({},"",i),z=new URL(i,location.href).pathname,h.reset(),c.send(new r("navigate",{path:i,flash:e},r.GetID()))}var u=class{constructor(t,e){this.event=t;this.attribute=e;this.limiter=new L}isWired(t){return t.hasAttribute(`${this.attribute}-wired`)?!0:(t.setAttribute(`${this.attribute}-wired`,""),!1)}attach(){document.querySelectorAll(`*[${this.attribute}]`).forEach(t=>{if(this.isWired(t)==!0)return;let e=g(t);t.addEventListener(this.event,s=>{this.limiter.hasDebounce(t)?this.limiter.debounce(t,s,this.handler(t,e)):this.handler(t,e)(s)}),t.addEventListener("ack",s=>{t.classList.remove(`${this.attribute}-loading`)})})}windowAttach(){document.querySelectorAll(`*[${this.attribute}]`).forEach(t=>{if(this.isWired(t)===!0)return;let e=g(t);window.addEventListener(this.event,this.handler(t,e)),window.addEventListener("ack",s=>{t.classList.remove(`${this.attribute}-loading`)})})}handler(t,e){return s=>{let n=t?.getAttribute(this.attribute);if(n!==null){if(h.isCommands(n)){h.exec(n,t,e);return}t.classList.add(`${this.attribute}-loading`),c.sendAndTrack(new r(n,e,r.GetID()),t)}}}},f=class extends u{handler(t,e){return s=>{let n=s,a=t?.getAttribute(this.attribute);if(a===null)return;let o=t.getAttribute("live-key");if(o!==null&&n.key!==o)return;let d={key:n.key,altKey:n.altKey,ctrlKey:n.ctrlKey,shiftKey:n.shiftKey,metaKey:n.metaKey};if(h.isCommands(a)){h.exec(a,t,{...e,...d});return}t.classList.add(`${this.attribute}-loading`),c.sendAndTrack(new r(a,{...e,...d},r.GetID()),t)}}},L=class{constructor(){this.debounceAttr="live-debounce"}hasDebounce(t){return t.hasAttribute(this.debounceAttr)}debounce(t,e,s){if(clearTimeout(this.debounceEvent),!this.hasDebounce(t)){s(e);return}let n=t.getAttribute(this.debounceAttr);if(n===null){s(e);return}if(n==="blur"){this.debounceEvent=s,t.addEventListener("blur",()=>{this.debounceEvent()});return}this.debounceEvent=setTimeout(()=>{s(e)},parseInt(n))}},S=class extends u{constructor(){super("click","live-click")}},T=class extends u{constructor(){super("contextmenu","live-contextmenu")}},A=class extends u{constructor(){super("mousedown","live-mousedown")}},M=class extends u{constructor(){super("mouseup","live-mouseup")}},x=class extends u{constructor(){super("focus","live-focus")}},P=class extends u{constructor(){super("blur","live-blur")}},D=class extends u{constructor(){super("focus","live-window-focus")}attach(){this.windowAttach()}},C=class extends u{constructor(){super("blur","live-window-blur")}attach(){this.windowAttach()}},$=class extends f{constructor(){super("keydown","live-keydown")}},R=class extends f{constructor(){super("keyup","live-keyup")}},F=class extends f{constructor(){super("keydown","live-window-keydown")}attach(){this.windowAttach()}},I=class extends f{constructor(){super("keyup","live-window-keyup")}attach(){this.windowAttach()}},q=class{constructor(){this.attribute="live-change";this.limiter=new L}isWired(t){return t.hasAttribute(`${this.attribute}-wired`)?!0:(t.setAttribute(`${this.attribute}-wired`,""),!1)}attach(){let t=[];document.querySelectorAll(`form[${this.attribute}]`).forEach(e=>{e.addEventListener("ack",s=>{e.classList.remove(`${this.attribute}-loading`)}),t.push(e),e.querySelectorAll("input,select,textarea").forEach(s=>{this.addEvent(e,s)})}),t.forEach(e=>{document.querySelectorAll(`[form=${e.getAttribute("id")}]`).forEach(s=>{this.addEvent(e,s)})})}addEvent(t,e){this.isWired(e)||e.addEventListener("input",s=>{this.limiter.hasDebounce(e)?this.limiter.debounce(e,s,()=>{this.handler(t)}):this.handler(t)})}handler(t){let e=t?.getAttribute(this.attribute);if(e===null)return;let s=p.serialize(t);t.classList.add(`${this.attribute}-loading`),c.sendAndTrack(new r(e,s,r.GetID()),t)}},U=class extends u{constructor(){super("submit","live-submit")}handler(t,e){return s=>{if(s.preventDefault&&s.preventDefault(),p.hasFiles(t)===!0){let a=new XMLHttpRequest;a.open("POST",c.withID(location.href)),a.addEventListener("load",()=>{this.sendEvent(t,e)}),a.send(new FormData(t))}else this.sendEvent(t,e);return!1}}sendEvent(t,e){let s=t?.getAttribute(this.attribute);if(s===null)return;var n={...e};let a=p.serialize(t);Object.keys(a).map(o=>{n[o]=a[o]}),t.classList.add(`${this.attribute}-loading`),c.sendAndTrack(new r(s,n,r.GetID()),t)}},N=class extends u{constructor(){super("","live-hook")}attach(){document.querySelectorAll(`[${this.attribute}]`).forEach(t=>{this.isWired(t)!=!0&&l.mounted(t)})}},K=class extends u{constructor(){super("click","live-patch")}handler(t,e){return s=>{s.preventDefault&&s.preventDefault();let n=t.getAttribute("href");if(n!==null)return k(n,t),!1}}},O=class extends u{constructor(){super("click","live-navigate")}handler(t,e){return s=>{let n=t.getAttribute("href");if(n!==null)return s.preventDefault&&s.preventDefault(),E(n),!1}}},m=class{static init(){this.clicks=new S,this.contextmenu=new T,this.mousedown=new A,this.mouseup=new M,this.focus=new x,this.blur=new P,this.windowFocus=new D,this.windowBlur=new C,this.keydown=new $,this.keyup=new R,this.windowKeydown=new F,this.windowKeyup=new I,this.change=new q,this.submit=new U,this.hook=new N,this.patch=new K,this.navigate=new O,this.handleBrowserNav()}static rewire(){this.clicks.attach(),this.contextmenu.attach(),this.mousedown.attach(),this.mouseup.attach(),this.focus.attach(),this.blur.attach(),this.windowFocus.attach(),this.windowBlur.attach(),this.keydown.attach(),this.keyup.attach(),this.windowKeyup.attach(),this.windowKeydown.attach(),this.change.attach(),this.submit.attach(),this.hook.attach(),this.patch.attach(),this.navigate.attach()}static handleBrowserNav(){window.onpopstate=function(t){if(B()){E(`${document.location.pathname}${document.location.search}`,!1);return}c.send(new r("params",y(document.location.search),r.GetID()))}}};var et="_psid",st="_pcsrf",nt="_pconnect",c=class i{static{this.csrf=null}static{this.ready=!1}static{this.disconnectNotified=!1}static{this.connectParams=()=>({})}static{this.pendingReplies={}}constructor(){}static getID(){if(this.id)return this.id;let t=document.querySelector("[live-rendered]")?.getAttribute("live-rendered");if(t)return t;let s=`; ${document.cookie}`.split(`; ${this.getIDName()}=`);if(s&&s.length===2){let n=s.pop();return n?n.split(";").shift():""}return""}static getIDName(){return document.querySelector("[live-socket-cookie]")?.getAttribute("live-socket-cookie")??et}static getCSRF(){return this.csrf!==null?this.csrf:document.querySelector("[live-csrf]")?.getAttribute("live-csrf")??null}static withID(t){let e=new URL(t,location.href);return this.id&&e.searchParams.set(this.getIDName(),this.id),this.csrf&&e.searchParams.set(st,this.csrf),e.toString()}static setConnectParams(t){this.connectParams=typeof t=="function"?t:()=>t}static getConnectParams(){return{timezone:Intl.DateTimeFormat().resolvedOptions().timeZone,locale:navigator.language,viewport:{width:window.innerWidth,height:window.innerHeight},...this.connectParams()}}static dial(){this.trackedEvents={},this.id=this.getID(),this.csrf=this.getCSRF(),console.debug("Socket.dial called",this.id);let t=new URL(this.withID(`${location.protocol==="https:"?"wss":"ws"}://${location.host}${location.pathname}${location.search}${location.hash}`));t.searchParams.set(nt,JSON.stringify(this.getConnectParams())),this.conn=new WebSocket(t.toString()),this.conn.addEventListener("close",e=>{this.ready=!1,this.rejectReplies(new Error("socket disconnected")),console.warn(`WebSocket Disconnected code: ${e.code}, reason: ${e.reason}`),e.code!==1001&&(this.disconnectNotified===!1&&(l.disconnected(),this.disconnectNotified=!0),setTimeout(()=>{i.dial()},1e3))}),this.conn.addEventListener("open",e=>{l.reconnected(),this.disconnectNotified=!1,this.ready=!0}),this.conn.addEventListener("message",e=>{if(typeof e.data!="string"){console.error("unexpected message type",typeof e.data);return}let s=r.fromMessage(e.data);switch(s.typ){case"connect":typeof s.data=="string"&&s.data!==""&&(this.id=s.data),l.handleEvent(s);break;case"id":typeof s.data=="string"&&s.data!==""&&(this.id=s.data);break;case"patch":w.handle(s),m.rewire();break;case"params":k(`${window.location.pathname}?${s.data}`);break;case"redirect":window.location.replace(s.data);break;case"navigate":E(s.data.path,!0,s.data.flash);break;case"flash":document.cookie=`_pflash=${s.data}; path=/; max-age=60; samesite=lax`;break;case"ack":this.ack(s);break;case"call":l.handleCall(s);break;case"exec":h.exec(s.data,null);break;case"err":this.rejectReply(s),l.error();default:l.handleEvent(s)}})}static sendAndTrack(t,e){if(this.ready===!1){console.warn("connection not ready for send of event",t);return}this.trackedEvents[t.id]={ev:t,el:e},this.conn.send(t.serialize())}static isReady(){return this.ready}static send(t){if(this.ready===!1){console.warn("connection not ready for send of event",t);return}this.conn.send(t.serialize())}static sendAndReply(t){return this.ready===!1?Promise.reject(new Error("connection not ready for send of event")):(t.id===0&&(t.id=r.GetID()),new Promise((e,s)=>{this.pendingReplies[t.id]={resolve:e,reject:s},this.conn.send(t.serialize())}))}static rejectReply(t){let e=t.data?.source?.i;e===void 0||!(e in this.pendingReplies)||(this.pendingReplies[e].reject(new Error(t.data.err)),delete this.pendingReplies[e])}static rejectReplies(t){for(let e in this.pendingReplies)this.pendingReplies[e].reject(t);this.pendingReplies={}}static ack(t){t.id in this.pendingReplies&&(this.pendingReplies[t.id].resolve(t.data),delete this.pendingReplies[t.id]),t.id in this.trackedEvents&&(this.trackedEvents[t.id].el.dispatchEvent(new Event("ack")),delete this.trackedEvents[t.id])}};var H=class{constructor(t,e,s){this.hooks=t;this.dom=e;this.params=s}init(){document.querySelector("[live-rendered]")!==null&&(l.init(this.hooks,this.dom),this.params!==void 0&&c.setConnectParams(this.params),c.dial(),m.init(),m.rewire())}send(t,e,s){let n=new r(t,e,s);c.send(n)}};document.addEventListener("DOMContentLoaded",i=>{window.Live!==void 0&&console.error("window.Live already defined");let t=window.Hooks||{};window.Live=new H(t,void 0,window.ConnectParams),window.Live.init()});})();
 //# sourceMappingURL=auto.js.map
@@ -1,9 +1,11 @@
 import { Live } from "./live";
 import { Hooks } from "./interop";
+import { ConnectParams } from "./socket";
 
 declare global {
     interface Window {
         Hooks: Hooks;
+        ConnectParams?: ConnectParams | (() => ConnectParams);
         Live: Live;
     }
 }
@@ -13,6 +15,6 @@ document.addEventListener("DOMContentLoaded", (_) => {
         console.error("window.Live already defined");
     }
     const hooks = window.Hooks || {};
-    window.Live = new Live(hooks);
+    window.Live = new Live(hooks, undefined, window.ConnectParams);
     window.Live.init();
 });
@@ -70,6 +70,10 @@ export class EventDispatch {
     private static hooks: Hooks;
     private static dom?: DOM;
     private static eventHandlers: { [e: string]: ((d: any) => void)[] };
+    private static callHandlers: WeakMap<
+        Element,
+        { [e: string]: (payload: any) => any }
+    >;
 
     constructor() {}
 
@@ -80,6 +84,7 @@ export class EventDispatch {
         this.hooks = hooks;
         this.dom = dom;
         this.eventHandlers = {};
+        this.callHandlers = new WeakMap();
     }
 
     /**
@@ -94,6 +99,27 @@ export class EventDispatch {
         });
     }
 
+    /**
+     * Handle a call from the server to the hook of an element,
+     * sending back what the hooks call handler returns.
+     */
+    static async handleCall(ev: LiveEvent) {
+        const { hook, event, payload } = ev.data;
+        let reply: any;
+        try {
+            const el = document.getElementById(hook);
+            const handler =
+                el === null ? undefined : this.callHandlers.get(el)?.[event];
+            if (handler === undefined) {
+                throw new Error(`no call handler for ${event} on ${hook}`);
+            }
+            reply = { reply: await handler(payload) };
+        } catch (e) {
+            reply = { err: e instanceof Error ? e.message : String(e) };
+        }
+        Socket.send(new LiveEvent("reply", reply, ev.id));
+    }
+
     /**
      * Handle an element being mounted.
      */
@@ -216,8 +242,9 @@ export class EventDispatch {
         if (f === undefined) {
             return;
         }
-        const pushEvent = (e: LiveEvent) => {
-            Socket.send(e);
+        const pushEvent = (e: LiveEvent | { t: string; d: any }) => {
+            const ev = e instanceof LiveEvent ? e : new LiveEvent(e.t, e.d);
+            return Socket.sendAndReply(ev);
         };
         const handleEvent = (e: string, cb: (d: any) => void) => {
             if (!(e in this.eventHandlers)) {
@@ -225,7 +252,12 @@ export class EventDispatch {
             }
             this.eventHandlers[e].push(cb);
         };
-        f.bind({ el, pushEvent, handleEvent })();
+        const handleCall = (e: string, cb: (payload: any) => any) => {
+            const handlers = this.callHandlers.get(el) || {};
+            handlers[e] = cb;
+            this.callHandlers.set(el, handlers);
+        };
+        f.bind({ el, pushEvent, handleEvent, handleCall })();
         el.dispatchEvent(event);
     }
 }
@@ -1,7 +1,15 @@
 import { Socket } from "./socket";
 import { Forms } from "./forms";
-import { UpdateURLParams, GetParams, GetURLParams, Params } from "./params";
+import {
+    UpdateURLParams,
+    GetParams,
+    GetURLParams,
+    Params,
+    Navigate,
+    IsNavigation,
+} from "./params";
 import { EventDispatch, LiveEvent } from "./event";
+import { JS } from "./js";
 
 /**
  * Standard event handler class. Clicks, focus and blur.
@@ -68,6 +76,10 @@ class LiveHandler {
             if (t === null) {
                 return;
             }
+            if (JS.isCommands(t)) {
+                JS.exec(t, element, params);
+                return;
+            }
             element.classList.add(`${this.attribute}-loading`);
             Socket.sendAndTrack(
                 new LiveEvent(t, params, LiveEvent.GetID()),
@@ -94,7 +106,6 @@ export class KeyHandler extends LiveHandler {
                     return;
                 }
             }
-            element.classList.add(`${this.attribute}-loading`);
             const keyData = {
                 key: ke.key,
                 altKey: ke.altKey,
@@ -102,6 +113,11 @@ export class KeyHandler extends LiveHandler {
                 shiftKey: ke.shiftKey,
                 metaKey: ke.metaKey,
             };
+            if (JS.isCommands(t)) {
+                JS.exec(t, element, { ...params, ...keyData });
+                return;
+            }
+            element.classList.add(`${this.attribute}-loading`);
             Socket.sendAndTrack(
                 new LiveEvent(t, { ...params, ...keyData }, LiveEvent.GetID()),
                 element
@@ -351,7 +367,7 @@ class Submit extends LiveHandler {
             const hasFiles = Forms.hasFiles(element as HTMLFormElement);
             if (hasFiles === true) {
                 const request = new XMLHttpRequest();
-                request.open("POST", "");
+                request.open("POST", Socket.withID(location.href));
                 request.addEventListener('load', () => {
                     this.sendEvent(element, params);
                 });
@@ -427,6 +443,27 @@ class Patch extends LiveHandler {
     }
 }
 
+/**
+ * live-navigate event handler.
+ */
+class NavigateLink extends LiveHandler {
+    constructor() {
+        super("click", "live-navigate");
+    }
+
+    protected handler(element: HTMLElement, _: Params): EventListener {
+        return (e: Event) => {
+            const path = element.getAttribute("href");
+            if (path === null) {
+                return;
+            }
+            if (e.preventDefault) e.preventDefault();
+            Navigate(path);
+            return false;
+        };
+    }
+}
+
 /**
  * Handle all events.
  */
@@ -447,6 +484,7 @@ export class Events {
     private static submit: Submit;
     private static hook: Hook;
     private static patch: Patch;
+    private static navigate: NavigateLink;
 
     /**
      * Initialise all the event wiring.
@@ -468,6 +506,7 @@ export class Events {
         this.submit = new Submit();
         this.hook = new Hook();
         this.patch = new Patch();
+        this.navigate = new NavigateLink();
 
         this.handleBrowserNav();
     }
@@ -492,6 +531,7 @@ export class Events {
         this.submit.attach();
         this.hook.attach();
         this.patch.attach();
+        this.navigate.attach();
     }
 
     /**
@@ -500,6 +540,13 @@ export class Events {
      */
     private static handleBrowserNav() {
         window.onpopstate = function (_: any) {
+            if (IsNavigation()) {
+                Navigate(
+                    `${document.location.pathname}${document.location.search}`,
+                    false
+                );
+                return;
+            }
             Socket.send(
                 new LiveEvent(
                     "params",
@@ -1,2 +1,3 @@
 export { Hooks, Hook } from "./interop";
 export { Live } from "./live";
+export type { ConnectParams } from "./socket";
@@ -0,0 +1,311 @@
+import { Socket } from "./socket";
+import { LiveEvent } from "./event";
+import { Params } from "./params";
+
+/**
+ * A command built by live.JSCommands on the server.
+ */
+interface Command {
+    op: string;
+    to?: string;
+    classes?: string[];
+    attr?: string;
+    value?: any;
+    event?: string;
+}
+
+/**
+ * A value set by a command, along with the value the server
+ * rendered before it.
+ */
+interface Saved<T> {
+    base: T;
+    value: T;
+}
+
+/**
+ * The state commands have set on an element, kept so that
+ * it can be put back after the element is patched.
+ */
+interface ElementState {
+    // el the element the state was last set on, a patched
+    // element is replaced by a new one.
+    el: HTMLElement;
+    display?: Saved<string>;
+    classes: { [c: string]: Saved<boolean> };
+    attrs: { [a: string]: Saved<string | null> };
+}
+
+/**
+ * Executes JS commands on the client.
+ */
+export class JS {
+    private static state: { [key: string]: ElementState } = {};
+
+    /**
+     * Is an attribute value a list of commands rather than an
+     * event name.
+     */
+    static isCommands(value: string): boolean {
+        return value.trimStart().startsWith("[");
+    }
+
+    /**
+     * Execute commands, relative to the element with the binding
+     * if there is one.
+     */
+    static exec(
+        commands: string | Command[],
+        source: HTMLElement | null,
+        params: Params = {}
+    ) {
+        const cmds: Command[] =
+            typeof commands === "string" ? JSON.parse(commands) : commands;
+        cmds.forEach((cmd) => {
+            if (cmd.op === "push") {
+                JS.push(cmd, source, params);
+                return;
+            }
+            JS.targets(cmd, source).forEach((el) => JS.apply(cmd, el));
+        });
+    }
+
+    /**
+     * Restore the state set by commands on elements which have
+     * been patched. State the server has since changed is
+     * forgotten, so that the server has the final say.
+     */
+    static restore() {
+        for (const key in this.state) {
+            const el = JS.find(key);
+            if (el === null) {
+                delete this.state[key];
+                continue;
+            }
+            const s = this.state[key];
+            if (el === s.el) {
+                continue;
+            }
+            s.el = el;
+            JS.restoreElement(el, s);
+            if (
+                s.display === undefined &&
+                Object.keys(s.classes).length === 0 &&
+                Object.keys(s.attrs).length === 0
+            ) {
+                delete this.state[key];
+            }
+        }
+    }
+
+    /**
+     * Forget the state set by commands, for when the view is
+     * replaced by navigating to another page.
+     */
+    static reset() {
+        this.state = {};
+    }
+
+    private static targets(
+        cmd: Command,
+        source: HTMLElement | null
+    ): HTMLElement[] {
+        if (cmd.to === undefined || cmd.to === "") {
+            return source === null ? [] : [source];
+        }
+        return Array.from(document.querySelectorAll<HTMLElement>(cmd.to));
+    }
+
+    private static apply(cmd: Command, el: HTMLElement) {
+        switch (cmd.op) {
+            case "show":
+                JS.setDisplay(el, "");
+                break;
+            case "hide":
+                JS.setDisplay(el, "none");
+                break;
+            case "toggle":
+                JS.setDisplay(
+                    el,
+                    getComputedStyle(el).display === "none" ? "" : "none"
+                );
+                break;
+            case "add_class":
+                (cmd.classes || []).forEach((c) => JS.setClass(el, c, true));
+                break;
+            case "remove_class":
+                (cmd.classes || []).forEach((c) => JS.setClass(el, c, false));
+                break;
+            case "set_attr":
+                JS.setAttr(el, cmd.attr || "", cmd.value);
+                break;
+            case "remove_attr":
+                JS.setAttr(el, cmd.attr || "", null);
+                break;
+            case "focus":
+                el.focus();
+                break;
+            case "dispatch":
+                el.dispatchEvent(
+                    new CustomEvent(cmd.event || "", {
+                        bubbles: true,
+                        detail: cmd.value,
+                    })
+                );
+                break;
+            default:
+                console.warn("unknown js command", cmd.op);
+        }
+    }
+
+    private static push(
+        cmd: Command,
+        source: HTMLElement | null,
+        params: Params
+    ) {
+        const e = new LiveEvent(
+            cmd.event || "",
+            { ...params, ...(cmd.value || {}) },
+            LiveEvent.GetID()
+        );
+        if (source === null) {
+            Socket.send(e);
+            return;
+        }
+        Socket.sendAndTrack(e, source);
+    }
+
+    private static setDisplay(el: HTMLElement, display: string) {
+        const s = JS.elementState(el);
+        if (s !== null) {
+            s.display = {
+                base: s.display?.base ?? el.style.display,
+                value: display,
+            };
+        }
+        el.style.display = display;
+    }
+
+    private static setClass(el: HTMLElement, c: string, on: boolean) {
+        const s = JS.elementState(el);
+        if (s !== null) {
+            s.classes[c] = {
+                base: s.classes[c]?.base ?? el.classList.contains(c),
+                value: on,
+            };
+        }
+        el.classList.toggle(c, on);
+    }
+
+    private static setAttr(
+        el: HTMLElement,
+        attr: string,
+        value: string | null
+    ) {
+        const s = JS.elementState(el);
+        if (s !== null) {
+            s.attrs[attr] = {
+                base:
+                    attr in s.attrs
+                        ? s.attrs[attr].base
+                        : el.getAttribute(attr),
+                value: value,
+            };
+        }
+        JS.writeAttr(el, attr, value);
+    }
+
+    private static writeAttr(
+        el: HTMLElement,
+        attr: string,
+        value: string | null
+    ) {
+        if (value === null) {
+            el.removeAttribute(attr);
+        } else {
+            el.setAttribute(attr, value);
+        }
+    }
+
+    /**
+     * Put back the state set by commands on a patched element
+     * where the server rendered what it did before, and forget
+     * any the server has changed.
+     */
+    private static restoreElement(el: HTMLElement, s: ElementState) {
+        if (
+            s.display !== undefined &&
+            !JS.reconcile(s.display, el.style.display, (v) => {
+                el.style.display = v;
+            })
+        ) {
+            delete s.display;
+        }
+        for (const c in s.classes) {
+            const current = el.classList.contains(c);
+            const keep = JS.reconcile(s.classes[c], current, (v) => {
+                el.classList.toggle(c, v);
+            });
+            if (!keep) {
+                delete s.classes[c];
+            }
+        }
+        for (const a in s.attrs) {
+            const current = el.getAttribute(a);
+            const keep = JS.reconcile(s.attrs[a], current, (v) => {
+                JS.writeAttr(el, a, v);
+            });
+            if (!keep) {
+                delete s.attrs[a];
+            }
+        }
+    }
+
+    /**
+     * Reconcile a saved value with the patched elements value,
+     * returning false if the server has changed it.
+     */
+    private static reconcile<T>(
+        saved: Saved<T>,
+        current: T,
+        apply: (v: T) => void
+    ): boolean {
+        if (current !== saved.base) {
+            return false;
+        }
+        apply(saved.value);
+        return true;
+    }
+
+    /**
+     * Get the state of an element, elements are identified by
+     * their id, or their live anchor which is stable across patches.
+     */
+    private static elementState(el: HTMLElement): ElementState | null {
+        const key = JS.key(el);
+        if (key === null) {
+            return null;
+        }
+        if (!(key in this.state)) {
+            this.state[key] = { el: el, classes: {}, attrs: {} };
+        }
+        this.state[key].el = el;
+        return this.state[key];
+    }
+
+    private static key(el: HTMLElement): string | null {
+        if (el.id !== "") {
+            return `#${CSS.escape(el.id)}`;
+        }
+        for (const a of Array.from(el.attributes)) {
+            if (a.name.startsWith("_l")) {
+                return `[${a.name}]`;
+            }
+        }
+        return null;
+    }
+
+    private static find(key: string): HTMLElement | null {
+        return document.querySelector<HTMLElement>(key);
+    }
+}
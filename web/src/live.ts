@@ -1,10 +1,14 @@
-import { Socket } from "./socket";
+import { Socket, ConnectParams } from "./socket";
 import { Events } from "./events";
 import { EventDispatch, LiveEvent } from "./event";
 import { Hooks, DOM } from "./interop";
 
 export class Live {
-    constructor(private hooks: Hooks, private dom?: DOM) {}
+    constructor(
+        private hooks: Hooks,
+        private dom?: DOM,
+        private params?: ConnectParams | (() => ConnectParams)
+    ) {}
 
     public init() {
         // Check that this document has been rendered by live.
@@ -15,6 +19,9 @@ export class Live {
         EventDispatch.init(this.hooks, this.dom);
 
         // Dial the server.
+        if (this.params !== undefined) {
+            Socket.setConnectParams(this.params);
+        }
         Socket.dial();
 
         // Initialise our live bindings.
@@ -1,5 +1,6 @@
 import { Socket } from "./socket";
 import { LiveEvent } from "./event";
+import { JS } from "./js";
 
 /**
  * A values from the "live-value-" attributes. As
@@ -73,3 +74,42 @@ export function UpdateURLParams(path: string, element?: HTMLElement) {
         );
     }
 }
+
+/**
+ * The path of the view currently mounted, used to tell a
+ * navigation apart from a params change.
+ */
+let viewPath = location.pathname;
+
+/**
+ * IsNavigation is the current location a different view to the
+ * one mounted.
+ */
+export function IsNavigation(): boolean {
+    return location.pathname !== viewPath;
+}
+
+/**
+ * Navigate mount the view for a path over the current socket,
+ * updating the browser history, along with any flash carried
+ * from the current view. If the socket isn't connected the
+ * browser loads the path instead.
+ */
+export function Navigate(path: string, push: boolean = true, flash?: string) {
+    if (!Socket.isReady()) {
+        if (flash) {
+            document.cookie = `_pflash=${flash}; path=/; max-age=60; samesite=lax`;
+        }
+        window.location.assign(path);
+        return;
+    }
+    if (push) {
+        window.history.pushState({}, "", path);
+    }
+    viewPath = new URL(path, location.href).pathname;
+    // The new view doesn't share elements with the old one.
+    JS.reset();
+    Socket.send(
+        new LiveEvent("navigate", { path, flash }, LiveEvent.GetID())
+    );
+}
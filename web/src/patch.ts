@@ -1,5 +1,6 @@
 import { LiveEvent, EventDispatch } from "./event";
 import { Forms } from "./forms";
+import { JS } from "./js";
 
 interface PatchEvent {
     Anchor: string;
@@ -17,6 +18,9 @@ export class Patch {
         const patches = event.data;
         patches.map(Patch.applyPatch);
 
+        // Put back any state set by JS commands on patched elements.
+        JS.restore();
+
         Forms.hydrate();
     }
 
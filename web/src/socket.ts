@@ -1,9 +1,18 @@
 import { EventDispatch, LiveEvent } from "./event";
 import { Patch } from "./patch";
 import { Events } from "./events";
-import { UpdateURLParams } from "./params";
+import { UpdateURLParams, Navigate } from "./params";
+import { JS } from "./js";
 
 const privateSocketID = "_psid"
+const privateCSRF = "_pcsrf"
+const privateConnectParams = "_pconnect"
+
+/**
+ * Params sent to the server when the socket connects, available
+ * to the mount handler from Socket.ConnectParams.
+ */
+export type ConnectParams = { [key: string]: any };
 
 /**
  * Represents the websocket connection to
@@ -11,22 +20,40 @@ const privateSocketID = "_psid"
  */
 export class Socket {
     private static id: string | undefined;
+    private static csrf: string | null = null;
     private static conn: WebSocket;
     private static ready: boolean = false;
     private static disconnectNotified: boolean = false;
+    private static connectParams: () => ConnectParams = () => ({});
 
     private static trackedEvents: {
         [id: number]: { ev: LiveEvent; el: HTMLElement };
     };
+    private static pendingReplies: {
+        [id: number]: {
+            resolve: (reply: any) => void;
+            reject: (err: Error) => void;
+        };
+    } = {};
 
     constructor() {}
 
+    /**
+     * The socket ID is rendered into the page by the server, which
+     * keeps each tab on its own socket. The cookie is only a fallback.
+     */
     static getID() {
         if (this.id) {
             return this.id;
         }
+        const rendered = document
+            .querySelector(`[live-rendered]`)
+            ?.getAttribute("live-rendered");
+        if (rendered) {
+            return rendered;
+        }
         const value = `; ${document.cookie}`;
-        const parts = value.split(`; ${privateSocketID}=`);
+        const parts = value.split(`; ${this.getIDName()}=`);
         if (parts && parts.length === 2) {
             const val = parts.pop()
             if (!val) {
@@ -37,25 +64,92 @@ export class Socket {
         return "";
     }
 
-    static setCookie() {
-        var date = new Date();
-        date.setTime(date.getTime() + (60*1000));
-        document.cookie = `${privateSocketID}=${this.id}; expires=${date.toUTCString()}; path=/`;
+    /**
+     * The name the socket ID is sent under, which is the name of the
+     * socket cookie. The server renders it into the page if it isn't
+     * the default.
+     */
+    static getIDName(): string {
+        return document
+            .querySelector(`[live-socket-cookie]`)
+            ?.getAttribute("live-socket-cookie") ?? privateSocketID;
+    }
+
+    /**
+     * The CSRF token rendered into the page, if the server
+     * has CSRF protection enabled.
+     */
+    static getCSRF() {
+        if (this.csrf !== null) {
+            return this.csrf;
+        }
+        return document
+            .querySelector(`[live-csrf]`)
+            ?.getAttribute("live-csrf") ?? null;
+    }
+
+    /**
+     * Add the socket ID and CSRF token to a URL so that the server
+     * can find this socket without relying on the shared cookie.
+     */
+    static withID(url: string): string {
+        const u = new URL(url, location.href);
+        if (this.id) {
+            u.searchParams.set(this.getIDName(), this.id);
+        }
+        if (this.csrf) {
+            u.searchParams.set(privateCSRF, this.csrf);
+        }
+        return u.toString();
+    }
+
+    /**
+     * Set the params sent to the server on connection, in addition
+     * to the defaults.
+     */
+    static setConnectParams(params: ConnectParams | (() => ConnectParams)) {
+        this.connectParams =
+            typeof params === "function" ? params : () => params;
+    }
+
+    /**
+     * The params sent when dialing, the clients timezone, locale and
+     * viewport along with any set by the user. Evaluated on every dial
+     * so that they are fresh on reconnect.
+     */
+    static getConnectParams(): ConnectParams {
+        return {
+            timezone: Intl.DateTimeFormat().resolvedOptions().timeZone,
+            locale: navigator.language,
+            viewport: {
+                width: window.innerWidth,
+                height: window.innerHeight,
+            },
+            ...this.connectParams(),
+        };
     }
 
     static dial() {
         this.trackedEvents = {};
         this.id = this.getID();
-        this.setCookie();
+        this.csrf = this.getCSRF();
 
         console.debug("Socket.dial called", this.id);
-        this.conn = new WebSocket(
-            `${location.protocol === "https:" ? "wss" : "ws"}://${
-                location.host
-            }${location.pathname}${location.search}${location.hash}`
+        const u = new URL(
+            this.withID(
+                `${location.protocol === "https:" ? "wss" : "ws"}://${
+                    location.host
+                }${location.pathname}${location.search}${location.hash}`
+            )
+        );
+        u.searchParams.set(
+            privateConnectParams,
+            JSON.stringify(this.getConnectParams())
         );
+        this.conn = new WebSocket(u.toString());
         this.conn.addEventListener("close", (ev) => {
             this.ready = false;
+            this.rejectReplies(new Error("socket disconnected"));
             console.warn(
                 `WebSocket Disconnected code: ${ev.code}, reason: ${ev.reason}`
             );
@@ -82,6 +176,21 @@ export class Socket {
             }
             const e = LiveEvent.fromMessage(ev.data);
             switch (e.typ) {
+                case "connect":
+                    // The server may hand out a freshly signed ID to
+                    // use when reconnecting.
+                    if (typeof e.data === "string" && e.data !== "") {
+                        this.id = e.data;
+                    }
+                    EventDispatch.handleEvent(e);
+                    break;
+                case "id":
+                    // A freshly signed ID, as the one in use will
+                    // expire.
+                    if (typeof e.data === "string" && e.data !== "") {
+                        this.id = e.data;
+                    }
+                    break;
                 case "patch":
                     Patch.handle(e);
                     Events.rewire();
@@ -92,10 +201,24 @@ export class Socket {
                 case "redirect":
                     window.location.replace(e.data);
                     break;
+                case "navigate":
+                    Navigate(e.data.path, true, e.data.flash);
+                    break;
+                case "flash":
+                    // Keep the flash for the page being redirected to.
+                    document.cookie = `_pflash=${e.data}; path=/; max-age=60; samesite=lax`;
+                    break;
                 case "ack":
                     this.ack(e);
                     break;
+                case "call":
+                    EventDispatch.handleCall(e);
+                    break;
+                case "exec":
+                    JS.exec(e.data, null);
+                    break;
                 case "err":
+                    this.rejectReply(e);
                     EventDispatch.error();
                 // Fallthrough here.
                 default:
@@ -120,6 +243,10 @@ export class Socket {
         this.conn.send(e.serialize());
     }
 
+    static isReady(): boolean {
+        return this.ready;
+    }
+
     static send(e: LiveEvent) {
         if (this.ready === false) {
             console.warn("connection not ready for send of event", e);
@@ -128,11 +255,56 @@ export class Socket {
         this.conn.send(e.serialize());
     }
 
+    /**
+     * Send an event and wait for the servers reply, which
+     * comes back with the ack.
+     */
+    static sendAndReply(e: LiveEvent): Promise<any> {
+        if (this.ready === false) {
+            return Promise.reject(
+                new Error("connection not ready for send of event")
+            );
+        }
+        if (e.id === 0) {
+            e.id = LiveEvent.GetID();
+        }
+        return new Promise((resolve, reject) => {
+            this.pendingReplies[e.id] = { resolve, reject };
+            this.conn.send(e.serialize());
+        });
+    }
+
+    /**
+     * Reject the reply to an event which errored.
+     */
+    private static rejectReply(e: LiveEvent) {
+        const id = e.data?.source?.i;
+        if (id === undefined || !(id in this.pendingReplies)) {
+            return;
+        }
+        this.pendingReplies[id].reject(new Error(e.data.err));
+        delete this.pendingReplies[id];
+    }
+
+    /**
+     * Reject all outstanding replies.
+     */
+    private static rejectReplies(err: Error) {
+        for (const id in this.pendingReplies) {
+            this.pendingReplies[id].reject(err);
+        }
+        this.pendingReplies = {};
+    }
+
     /**
      * Called when a ack event comes in. Complete the loop
      * with any outstanding tracked events.
      */
     static ack(e: LiveEvent) {
+        if (e.id in this.pendingReplies) {
+            this.pendingReplies[e.id].resolve(e.data);
+            delete this.pendingReplies[e.id];
+        }
         if (!(e.id in this.trackedEvents)) {
             return;
         }